@@ -0,0 +1,193 @@
+package GameServer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// accessReloadInterval is how often the access list file is checked for changes
+const accessReloadInterval = 5 * time.Second
+
+// AccessEntry matches clients by IP (a single address or a CIDR) and/or SteamID, an entry with both needs both to match
+type AccessEntry struct {
+	IP      string    `json:"ip,omitempty"`
+	SteamID string    `json:"steam_id,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Expires time.Time `json:"expires,omitzero"` // zero never expires
+
+	prefix netip.Prefix
+}
+
+// AccessList is the file at GameServer.AccessListPath, for example:
+//
+//	{
+//	  "bans": [
+//	    {"ip": "203.0.113.0/24", "reason": "spam", "expires": "2026-12-01T00:00:00Z"},
+//	    {"steam_id": "76561198000000000", "reason": "griefing"}
+//	  ],
+//	  "allow": []
+//	}
+//
+// If allow has entries only clients matching one of them get in. Bans are checked first.
+type AccessList struct {
+	Bans  []AccessEntry `json:"bans"`
+	Allow []AccessEntry `json:"allow"`
+}
+
+func LoadAccessList(path string) (*AccessList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list AccessList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid access list %s : %w", path, err)
+	}
+	for _, entries := range [][]AccessEntry{list.Bans, list.Allow} {
+		for i := range entries {
+			e := &entries[i]
+			if e.IP == "" && e.SteamID == "" {
+				return nil, fmt.Errorf("access list entry %d matches everyone, it needs an ip or a steam_id", i)
+			}
+			if e.IP == "" {
+				continue
+			}
+			if strings.Contains(e.IP, "/") {
+				e.prefix, err = netip.ParsePrefix(e.IP)
+			} else {
+				var addr netip.Addr
+				addr, err = netip.ParseAddr(e.IP)
+				e.prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+			}
+			if err != nil {
+				return nil, fmt.Errorf("access list entry %d : %w", i, err)
+			}
+			e.prefix = e.prefix.Masked()
+		}
+	}
+	return &list, nil
+}
+
+func (e *AccessEntry) matches(ip netip.Addr, steamID string, now time.Time) bool {
+	if !e.Expires.IsZero() && now.After(e.Expires) {
+		return false
+	}
+	if e.IP != "" && !e.prefix.Contains(ip) {
+		return false
+	}
+	return e.SteamID == "" || e.SteamID == steamID
+}
+
+// Check returns the ban that keeps the client out, or whether it is missing from the allow list
+func (l *AccessList) Check(ip netip.Addr, steamID string, now time.Time) (ban *AccessEntry, allowed bool) {
+	ip = ip.Unmap()
+	for i := range l.Bans {
+		if l.Bans[i].matches(ip, steamID, now) {
+			return &l.Bans[i], false
+		}
+	}
+	if len(l.Allow) == 0 {
+		return nil, true
+	}
+	for i := range l.Allow {
+		if l.Allow[i].matches(ip, steamID, now) {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+// denied checks a client against the access list, it returns the error to turn it away with or "" if it is let in
+func (s *GameServer) denied(c *Client, steamID string) (reason string, details map[string]string) {
+	list := s.access.Load()
+	if list == nil {
+		return "", nil
+	}
+	ip, _ := netip.ParseAddr(c.IP)
+	ban, allowed := list.Check(ip, steamID, time.Now())
+	switch {
+	case ban != nil:
+		expires := "never"
+		if !ban.Expires.IsZero() {
+			expires = ban.Expires.UTC().Format(time.RFC3339)
+		}
+		log.WithFields(log.Fields{"IP": c.IP, "SteamID": steamID, "reason": ban.Reason, "expires": expires}).Debug("Rejecting banned client")
+		return "BANNED", map[string]string{"reason": ban.Reason, "expires": expires}
+	case !allowed:
+		log.WithFields(log.Fields{"IP": c.IP, "SteamID": steamID}).Debug("Rejecting client missing from the allow list")
+		return "NOT_ALLOWED", nil
+	}
+	return "", nil
+}
+
+// admit turns a client in the handshake away if the access list doesn't let it in
+func (s *GameServer) admit(c *Client, steamID string) bool {
+	reason, details := s.denied(c, steamID)
+	if reason == "" {
+		return true
+	}
+	// kicked players don't keep their seat for a resume
+	s.markLeaving(c)
+	c.RespondErrorDetails(reason, details, true)
+	return false
+}
+
+// ReloadAccessList reads AccessListPath again and kicks connected players the new list doesn't let in
+func (s *GameServer) ReloadAccessList() error {
+	list, err := LoadAccessList(s.AccessListPath)
+	if err != nil {
+		return err
+	}
+	s.access.Store(list)
+	log.WithFields(log.Fields{"bans": len(list.Bans), "allow": len(list.Allow)}).Info("Loaded access list")
+	for _, c := range s.clientList() {
+		if c.ConnectedPlayer == nil {
+			continue
+		}
+		if reason, details := s.denied(c, c.ConnectedPlayer.SteamID); reason != "" {
+			s.markLeaving(c)
+			c.DisconnectDetails(reason, details)
+		}
+	}
+	// players waiting to resume would otherwise come right back in
+	var expired []*Client
+	s.suspendedMu.Lock()
+	for key, sc := range s.suspended {
+		if reason, _ := s.denied(sc.client, sc.client.ConnectedPlayer.SteamID); reason != "" && sc.timer.Stop() {
+			delete(s.suspended, key)
+			expired = append(expired, sc.client)
+		}
+	}
+	s.suspendedMu.Unlock()
+	for _, c := range expired {
+		c.ConnectedPlayer.Reconnecting = false
+		s.removeClient(c)
+	}
+	return nil
+}
+
+// accessListWatcher reloads the access list whenever the file changes. A broken file keeps the previous list.
+func (s *GameServer) accessListWatcher() {
+	var modified time.Time
+	if info, err := os.Stat(s.AccessListPath); err == nil {
+		modified = info.ModTime()
+	}
+	ticker := time.NewTicker(accessReloadInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		info, err := os.Stat(s.AccessListPath)
+		if err != nil || info.ModTime().Equal(modified) {
+			continue
+		}
+		modified = info.ModTime()
+		if err := s.ReloadAccessList(); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to reload access list")
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package GameServer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ProtocolVersion is the newest payload layout this server speaks, see the since option of monotag
+const ProtocolVersion int32 = 5
+
+// MinProtocolVersion is the oldest payload layout the server still speaks, clients below it are turned away
+const MinProtocolVersion int32 = 1
+
+// MonoMarshaler is implemented by payload types that have a generated encoder (see tools/monogen).
+// AddToPayload prefers it over the reflection based serializer.
+type MonoMarshaler interface {
+	MarshalMono(buf *bytes.Buffer, version int32) error
+}
+
+// MonoUnmarshaler is the decoding counterpart of MonoMarshaler, used by ReadPayload.
+type MonoUnmarshaler interface {
+	UnmarshalMono(r *bytes.Reader, version int32) error
+}
+
+// Helpers used by both the reflection serializer and the generated codecs, so the two can't drift apart.
+//
+// Wire layout (everything little endian):
+//   - fixed width ints, uints and floats are written as is, bools take one byte
+//   - strings and slices are prefixed with an int32 length
+//   - arrays are written element by element without a length
+//   - maps are prefixed with an int32 length, entries follow sorted by key
+//   - pointers are prefixed with a presence byte, 0x00 for nil and 0x01 followed by the value otherwise
+//   - struct fields go in declaration order, `mono` tags can skip, limit, narrow or version them (see monotag)
+
+func writeUint8(buf *bytes.Buffer, v uint8) {
+	buf.WriteByte(v)
+}
+
+func writeUint16(buf *bytes.Buffer, v uint16) {
+	var b [2]byte
+	Endianess.PutUint16(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	Endianess.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeUint64(buf *bytes.Buffer, v uint64) {
+	var b [8]byte
+	Endianess.PutUint64(b[:], v)
+	buf.Write(b[:])
+}
+
+// writeUintN writes the low size bytes of v
+func writeUintN(buf *bytes.Buffer, size int, v uint64) {
+	switch size {
+	case 1:
+		writeUint8(buf, uint8(v))
+	case 2:
+		writeUint16(buf, uint16(v))
+	case 4:
+		writeUint32(buf, uint32(v))
+	case 8:
+		writeUint64(buf, v)
+	}
+}
+
+func writeInt8(buf *bytes.Buffer, v int8)   { writeUint8(buf, uint8(v)) }
+func writeInt16(buf *bytes.Buffer, v int16) { writeUint16(buf, uint16(v)) }
+func writeInt32(buf *bytes.Buffer, v int32) { writeUint32(buf, uint32(v)) }
+func writeInt64(buf *bytes.Buffer, v int64) { writeUint64(buf, uint64(v)) }
+
+func writeFloat32(buf *bytes.Buffer, v float32) { writeUint32(buf, math.Float32bits(v)) }
+func writeFloat64(buf *bytes.Buffer, v float64) { writeUint64(buf, math.Float64bits(v)) }
+
+func writeBool(buf *bytes.Buffer, v bool) {
+	if v {
+		buf.WriteByte(0x01)
+	} else {
+		buf.WriteByte(0x00)
+	}
+}
+
+func writeString(buf *bytes.Buffer, v string) {
+	writeInt32(buf, int32(len(v)))
+	buf.WriteString(v)
+}
+
+func readUint8(r *bytes.Reader) (uint8, error) {
+	return r.ReadByte()
+}
+
+func readUint16(r *bytes.Reader) (uint16, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return Endianess.Uint16(b[:]), nil
+}
+
+func readUint32(r *bytes.Reader) (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return Endianess.Uint32(b[:]), nil
+}
+
+func readUint64(r *bytes.Reader) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return Endianess.Uint64(b[:]), nil
+}
+
+// readUintN reads an unsigned integer that takes size bytes on the wire
+func readUintN(r *bytes.Reader, size int) (uint64, error) {
+	switch size {
+	case 1:
+		v, err := readUint8(r)
+		return uint64(v), err
+	case 2:
+		v, err := readUint16(r)
+		return uint64(v), err
+	case 4:
+		v, err := readUint32(r)
+		return uint64(v), err
+	case 8:
+		return readUint64(r)
+	}
+	return 0, fmt.Errorf("unsupported integer width %d", size)
+}
+
+func readInt8(r *bytes.Reader) (int8, error) {
+	v, err := readUint8(r)
+	return int8(v), err
+}
+
+func readInt16(r *bytes.Reader) (int16, error) {
+	v, err := readUint16(r)
+	return int16(v), err
+}
+
+func readInt32(r *bytes.Reader) (int32, error) {
+	v, err := readUint32(r)
+	return int32(v), err
+}
+
+func readInt64(r *bytes.Reader) (int64, error) {
+	v, err := readUint64(r)
+	return int64(v), err
+}
+
+func readFloat32(r *bytes.Reader) (float32, error) {
+	v, err := readUint32(r)
+	return math.Float32frombits(v), err
+}
+
+func readFloat64(r *bytes.Reader) (float64, error) {
+	v, err := readUint64(r)
+	return math.Float64frombits(v), err
+}
+
+func readBool(r *bytes.Reader) (bool, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	return b != 0, nil
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	return readStringMax(r, 0)
+}
+
+// readStringMax is readString for fields tagged with max, 0 means no limit
+func readStringMax(r *bytes.Reader, max int) (string, error) {
+	n, err := readLengthMax(r, max)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// readLength reads an int32 length prefix and checks it against what is left in the reader.
+// Strings, slice elements and map entries all take at least one byte, so a longer length can only be garbage
+// (and would otherwise let a 7 byte packet allocate gigabytes).
+func readLength(r *bytes.Reader) (int, error) {
+	n, err := readInt32(r)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || int(n) > r.Len() {
+		return 0, fmt.Errorf("invalid length %d with %d bytes left", n, r.Len())
+	}
+	return int(n), nil
+}
+
+// readLengthMax is readLength for fields tagged with max, 0 means no limit
+func readLengthMax(r *bytes.Reader, max int) (int, error) {
+	n, err := readLength(r)
+	if err != nil {
+		return 0, err
+	}
+	if max > 0 && n > max {
+		return 0, fmt.Errorf("length %d over maximum of %d", n, max)
+	}
+	return n, nil
+}
+
+// readPresence reads the byte in front of a pointer value
+func readPresence(r *bytes.Reader) (bool, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	switch b {
+	case 0x00:
+		return false, nil
+	case 0x01:
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid presence byte 0x%02x", b)
+	}
+}
@@ -0,0 +1,95 @@
+package GameServer
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// monoPayload is what every generated codec implements
+type monoPayload interface {
+	MonoMarshaler
+	MonoUnmarshaler
+}
+
+func benchTransforms(players int) *PlayerTransformsPacket {
+	p := &PlayerTransformsPacket{Players: make([]PlayerData, players)}
+	for i := range p.Players {
+		v := Vector3{float32(i), float32(i) * 2, float32(i) * 3}
+		p.Players[i] = PlayerData{PlayerID: int32(i), Transforms: Transforms{v, v, v, v}, Inputs: Inputs{IsMoving: true, MoveDirection: v}}
+	}
+	return p
+}
+
+func benchLobbyInfo(players int) *NetworkLobbyInfo {
+	info := &NetworkLobbyInfo{LobbyName: "Benchmark lobby", MapName: Maps.Grid, MaxPlayers: int32(players), Settings: map[string]string{"difficulty": "hard", "monsters": "3"}, JoinCode: "ABCDEF"}
+	for i := 0; i < players; i++ {
+		info.Players = append(info.Players, NetworkPlayerInfo{ID: int32(i), Name: fmt.Sprintf("Player %d", i), Cosmetics: []string{"hat", "scarf"}, Skin: "default"})
+	}
+	return info
+}
+
+var benchPayloads = []struct {
+	name    string
+	payload monoPayload
+	empty   func() monoPayload
+}{
+	{"Hello", &HelloPacket{Name: "Player", SteamID: "76561198000000000", Version: "1.2.3", Capabilities: 7, ProtocolVersion: ProtocolVersion}, func() monoPayload { return &HelloPacket{} }},
+	{"PlayerTransforms16", benchTransforms(16), func() monoPayload { return &PlayerTransformsPacket{} }},
+	{"LobbyInfo8", benchLobbyInfo(8), func() monoPayload { return &NetworkLobbyInfo{} }},
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	for _, bp := range benchPayloads {
+		b.Run(bp.name+"/Generated", func(b *testing.B) {
+			var buf bytes.Buffer
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if err := bp.payload.MarshalMono(&buf, ProtocolVersion); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.SetBytes(int64(buf.Len()))
+		})
+		b.Run(bp.name+"/Reflection", func(b *testing.B) {
+			var buf bytes.Buffer
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if err := serializeData(&buf, bp.payload, ProtocolVersion); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.SetBytes(int64(buf.Len()))
+		})
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	for _, bp := range benchPayloads {
+		var buf bytes.Buffer
+		if err := bp.payload.MarshalMono(&buf, ProtocolVersion); err != nil {
+			b.Fatal(err)
+		}
+		data := buf.Bytes()
+		b.Run(bp.name+"/Generated", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if err := bp.empty().UnmarshalMono(bytes.NewReader(data), ProtocolVersion); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(bp.name+"/Reflection", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if err := deserializeData(bytes.NewReader(data), bp.empty(), ProtocolVersion); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
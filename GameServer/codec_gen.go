@@ -0,0 +1,1467 @@
+// Code generated by monogen. DO NOT EDIT.
+
+package GameServer
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+var _ MonoMarshaler = (*AckPacket)(nil)
+var _ MonoUnmarshaler = (*AckPacket)(nil)
+
+func (x *AckPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeUint16(buf, x.Sequence)
+	writeUint32(buf, x.AckBits)
+	return nil
+}
+
+func (x *AckPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.Sequence, err = readUint16(r); err != nil {
+		return fmt.Errorf("AckPacket.Sequence: %w", err)
+	}
+	if x.AckBits, err = readUint32(r); err != nil {
+		return fmt.Errorf("AckPacket.AckBits: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*BindChallengePacket)(nil)
+var _ MonoUnmarshaler = (*BindChallengePacket)(nil)
+
+func (x *BindChallengePacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	if len(x.Cookie) > 64 {
+		return fmt.Errorf("BindChallengePacket.Cookie: length %d over maximum of 64", len(x.Cookie))
+	}
+	writeInt32(buf, int32(len(x.Cookie)))
+	for i0 := range x.Cookie {
+		writeUint8(buf, x.Cookie[i0])
+	}
+	return nil
+}
+
+func (x *BindChallengePacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	{
+		n0, err := readLengthMax(r, 64)
+		if err != nil {
+			return fmt.Errorf("BindChallengePacket.Cookie: %w", err)
+		}
+		x.Cookie = make([]byte, n0)
+		for i0 := range x.Cookie {
+			if x.Cookie[i0], err = readUint8(r); err != nil {
+				return fmt.Errorf("BindChallengePacket.Cookie[]: %w", err)
+			}
+		}
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*BindResponsePacket)(nil)
+var _ MonoUnmarshaler = (*BindResponsePacket)(nil)
+
+func (x *BindResponsePacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.ID)
+	if len(x.Cookie) > 64 {
+		return fmt.Errorf("BindResponsePacket.Cookie: length %d over maximum of 64", len(x.Cookie))
+	}
+	writeInt32(buf, int32(len(x.Cookie)))
+	for i0 := range x.Cookie {
+		writeUint8(buf, x.Cookie[i0])
+	}
+	if len(x.MAC) > 32 {
+		return fmt.Errorf("BindResponsePacket.MAC: length %d over maximum of 32", len(x.MAC))
+	}
+	writeInt32(buf, int32(len(x.MAC)))
+	for i0 := range x.MAC {
+		writeUint8(buf, x.MAC[i0])
+	}
+	return nil
+}
+
+func (x *BindResponsePacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.ID, err = readInt32(r); err != nil {
+		return fmt.Errorf("BindResponsePacket.ID: %w", err)
+	}
+	{
+		n0, err := readLengthMax(r, 64)
+		if err != nil {
+			return fmt.Errorf("BindResponsePacket.Cookie: %w", err)
+		}
+		x.Cookie = make([]byte, n0)
+		for i0 := range x.Cookie {
+			if x.Cookie[i0], err = readUint8(r); err != nil {
+				return fmt.Errorf("BindResponsePacket.Cookie[]: %w", err)
+			}
+		}
+	}
+	{
+		n0, err := readLengthMax(r, 32)
+		if err != nil {
+			return fmt.Errorf("BindResponsePacket.MAC: %w", err)
+		}
+		x.MAC = make([]byte, n0)
+		for i0 := range x.MAC {
+			if x.MAC[i0], err = readUint8(r); err != nil {
+				return fmt.Errorf("BindResponsePacket.MAC[]: %w", err)
+			}
+		}
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*CreateLobbyPacket)(nil)
+var _ MonoUnmarshaler = (*CreateLobbyPacket)(nil)
+
+func (x *CreateLobbyPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	if len(x.Name) > 256 {
+		return fmt.Errorf("CreateLobbyPacket.Name: string length %d over maximum of 256", len(x.Name))
+	}
+	writeString(buf, x.Name)
+	writeInt32(buf, x.MaxPlayers)
+	writeBool(buf, x.IsPasswordProtected)
+	if len(x.Password) > 64 {
+		return fmt.Errorf("CreateLobbyPacket.Password: string length %d over maximum of 64", len(x.Password))
+	}
+	writeString(buf, x.Password)
+	return nil
+}
+
+func (x *CreateLobbyPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.Name, err = readStringMax(r, 256); err != nil {
+		return fmt.Errorf("CreateLobbyPacket.Name: %w", err)
+	}
+	if x.MaxPlayers, err = readInt32(r); err != nil {
+		return fmt.Errorf("CreateLobbyPacket.MaxPlayers: %w", err)
+	}
+	if x.IsPasswordProtected, err = readBool(r); err != nil {
+		return fmt.Errorf("CreateLobbyPacket.IsPasswordProtected: %w", err)
+	}
+	if x.Password, err = readStringMax(r, 64); err != nil {
+		return fmt.Errorf("CreateLobbyPacket.Password: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*ErrorPacket)(nil)
+var _ MonoUnmarshaler = (*ErrorPacket)(nil)
+
+func (x *ErrorPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeString(buf, x.Message)
+	writeInt32(buf, int32(len(x.Details)))
+	for _, k0 := range slices.Sorted(maps.Keys(x.Details)) {
+		v0 := x.Details[k0]
+		writeString(buf, k0)
+		writeString(buf, v0)
+	}
+	return nil
+}
+
+func (x *ErrorPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.Message, err = readString(r); err != nil {
+		return fmt.Errorf("ErrorPacket.Message: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	{
+		n0, err := readLengthMax(r, 0)
+		if err != nil {
+			return fmt.Errorf("ErrorPacket.Details: %w", err)
+		}
+		x.Details = make(map[string]string, n0)
+		for range n0 {
+			var k0 string
+			var v0 string
+			if k0, err = readString(r); err != nil {
+				return fmt.Errorf("ErrorPacket.Details{key}: %w", err)
+			}
+			if v0, err = readString(r); err != nil {
+				return fmt.Errorf("ErrorPacket.Details{}: %w", err)
+			}
+			x.Details[k0] = v0
+		}
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*FragmentAckPacket)(nil)
+var _ MonoUnmarshaler = (*FragmentAckPacket)(nil)
+
+func (x *FragmentAckPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeUint32(buf, x.MessageID)
+	writeUint16(buf, x.Index)
+	return nil
+}
+
+func (x *FragmentAckPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.MessageID, err = readUint32(r); err != nil {
+		return fmt.Errorf("FragmentAckPacket.MessageID: %w", err)
+	}
+	if x.Index, err = readUint16(r); err != nil {
+		return fmt.Errorf("FragmentAckPacket.Index: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*FragmentHeader)(nil)
+var _ MonoUnmarshaler = (*FragmentHeader)(nil)
+
+func (x *FragmentHeader) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeUint32(buf, x.MessageID)
+	writeUint16(buf, x.Index)
+	writeUint16(buf, x.Count)
+	return nil
+}
+
+func (x *FragmentHeader) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.MessageID, err = readUint32(r); err != nil {
+		return fmt.Errorf("FragmentHeader.MessageID: %w", err)
+	}
+	if x.Index, err = readUint16(r); err != nil {
+		return fmt.Errorf("FragmentHeader.Index: %w", err)
+	}
+	if x.Count, err = readUint16(r); err != nil {
+		return fmt.Errorf("FragmentHeader.Count: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*HeartbeatPacket)(nil)
+var _ MonoUnmarshaler = (*HeartbeatPacket)(nil)
+
+func (x *HeartbeatPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeUint32(buf, x.Sequence)
+	return nil
+}
+
+func (x *HeartbeatPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.Sequence, err = readUint32(r); err != nil {
+		return fmt.Errorf("HeartbeatPacket.Sequence: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*HelloPacket)(nil)
+var _ MonoUnmarshaler = (*HelloPacket)(nil)
+
+func (x *HelloPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	if len(x.Name) > 128 {
+		return fmt.Errorf("HelloPacket.Name: string length %d over maximum of 128", len(x.Name))
+	}
+	writeString(buf, x.Name)
+	if len(x.SteamID) > 32 {
+		return fmt.Errorf("HelloPacket.SteamID: string length %d over maximum of 32", len(x.SteamID))
+	}
+	writeString(buf, x.SteamID)
+	if len(x.Version) > 32 {
+		return fmt.Errorf("HelloPacket.Version: string length %d over maximum of 32", len(x.Version))
+	}
+	writeString(buf, x.Version)
+	writeUint32(buf, x.Capabilities)
+	if len(x.PublicKey) > 32 {
+		return fmt.Errorf("HelloPacket.PublicKey: length %d over maximum of 32", len(x.PublicKey))
+	}
+	writeInt32(buf, int32(len(x.PublicKey)))
+	for i0 := range x.PublicKey {
+		writeUint8(buf, x.PublicKey[i0])
+	}
+	writeInt32(buf, x.ProtocolVersion)
+	if len(x.ResumeToken) > 32 {
+		return fmt.Errorf("HelloPacket.ResumeToken: length %d over maximum of 32", len(x.ResumeToken))
+	}
+	writeInt32(buf, int32(len(x.ResumeToken)))
+	for i0 := range x.ResumeToken {
+		writeUint8(buf, x.ResumeToken[i0])
+	}
+	return nil
+}
+
+func (x *HelloPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.Name, err = readStringMax(r, 128); err != nil {
+		return fmt.Errorf("HelloPacket.Name: %w", err)
+	}
+	if x.SteamID, err = readStringMax(r, 32); err != nil {
+		return fmt.Errorf("HelloPacket.SteamID: %w", err)
+	}
+	if x.Version, err = readStringMax(r, 32); err != nil {
+		return fmt.Errorf("HelloPacket.Version: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	if x.Capabilities, err = readUint32(r); err != nil {
+		return fmt.Errorf("HelloPacket.Capabilities: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	{
+		n0, err := readLengthMax(r, 32)
+		if err != nil {
+			return fmt.Errorf("HelloPacket.PublicKey: %w", err)
+		}
+		x.PublicKey = make([]byte, n0)
+		for i0 := range x.PublicKey {
+			if x.PublicKey[i0], err = readUint8(r); err != nil {
+				return fmt.Errorf("HelloPacket.PublicKey[]: %w", err)
+			}
+		}
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	if x.ProtocolVersion, err = readInt32(r); err != nil {
+		return fmt.Errorf("HelloPacket.ProtocolVersion: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	{
+		n0, err := readLengthMax(r, 32)
+		if err != nil {
+			return fmt.Errorf("HelloPacket.ResumeToken: %w", err)
+		}
+		x.ResumeToken = make([]byte, n0)
+		for i0 := range x.ResumeToken {
+			if x.ResumeToken[i0], err = readUint8(r); err != nil {
+				return fmt.Errorf("HelloPacket.ResumeToken[]: %w", err)
+			}
+		}
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*IDAssignPacket)(nil)
+var _ MonoUnmarshaler = (*IDAssignPacket)(nil)
+
+func (x *IDAssignPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.ID)
+	writeUint32(buf, x.Capabilities)
+	if len(x.PublicKey) > 32 {
+		return fmt.Errorf("IDAssignPacket.PublicKey: length %d over maximum of 32", len(x.PublicKey))
+	}
+	writeInt32(buf, int32(len(x.PublicKey)))
+	for i0 := range x.PublicKey {
+		writeUint8(buf, x.PublicKey[i0])
+	}
+	writeInt32(buf, x.ProtocolVersion)
+	if len(x.ResumeToken) > 32 {
+		return fmt.Errorf("IDAssignPacket.ResumeToken: length %d over maximum of 32", len(x.ResumeToken))
+	}
+	writeInt32(buf, int32(len(x.ResumeToken)))
+	for i0 := range x.ResumeToken {
+		writeUint8(buf, x.ResumeToken[i0])
+	}
+	writeBool(buf, x.Resumed)
+	if len(x.UDPSecret) > 32 {
+		return fmt.Errorf("IDAssignPacket.UDPSecret: length %d over maximum of 32", len(x.UDPSecret))
+	}
+	writeInt32(buf, int32(len(x.UDPSecret)))
+	for i0 := range x.UDPSecret {
+		writeUint8(buf, x.UDPSecret[i0])
+	}
+	return nil
+}
+
+func (x *IDAssignPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.ID, err = readInt32(r); err != nil {
+		return fmt.Errorf("IDAssignPacket.ID: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	if x.Capabilities, err = readUint32(r); err != nil {
+		return fmt.Errorf("IDAssignPacket.Capabilities: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	{
+		n0, err := readLengthMax(r, 32)
+		if err != nil {
+			return fmt.Errorf("IDAssignPacket.PublicKey: %w", err)
+		}
+		x.PublicKey = make([]byte, n0)
+		for i0 := range x.PublicKey {
+			if x.PublicKey[i0], err = readUint8(r); err != nil {
+				return fmt.Errorf("IDAssignPacket.PublicKey[]: %w", err)
+			}
+		}
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	if x.ProtocolVersion, err = readInt32(r); err != nil {
+		return fmt.Errorf("IDAssignPacket.ProtocolVersion: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	{
+		n0, err := readLengthMax(r, 32)
+		if err != nil {
+			return fmt.Errorf("IDAssignPacket.ResumeToken: %w", err)
+		}
+		x.ResumeToken = make([]byte, n0)
+		for i0 := range x.ResumeToken {
+			if x.ResumeToken[i0], err = readUint8(r); err != nil {
+				return fmt.Errorf("IDAssignPacket.ResumeToken[]: %w", err)
+			}
+		}
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	if x.Resumed, err = readBool(r); err != nil {
+		return fmt.Errorf("IDAssignPacket.Resumed: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	{
+		n0, err := readLengthMax(r, 32)
+		if err != nil {
+			return fmt.Errorf("IDAssignPacket.UDPSecret: %w", err)
+		}
+		x.UDPSecret = make([]byte, n0)
+		for i0 := range x.UDPSecret {
+			if x.UDPSecret[i0], err = readUint8(r); err != nil {
+				return fmt.Errorf("IDAssignPacket.UDPSecret[]: %w", err)
+			}
+		}
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*ImHerePacket)(nil)
+var _ MonoUnmarshaler = (*ImHerePacket)(nil)
+
+func (x *ImHerePacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.ID)
+	return nil
+}
+
+func (x *ImHerePacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.ID, err = readInt32(r); err != nil {
+		return fmt.Errorf("ImHerePacket.ID: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*Inputs)(nil)
+var _ MonoUnmarshaler = (*Inputs)(nil)
+
+func (x *Inputs) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeBool(buf, x.IsSprinting)
+	writeBool(buf, x.IsMoving)
+	writeBool(buf, x.IsCrouching)
+	if err := x.MoveDirection.MarshalMono(buf, version); err != nil {
+		return fmt.Errorf("Inputs.MoveDirection: %w", err)
+	}
+	return nil
+}
+
+func (x *Inputs) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.IsSprinting, err = readBool(r); err != nil {
+		return fmt.Errorf("Inputs.IsSprinting: %w", err)
+	}
+	if x.IsMoving, err = readBool(r); err != nil {
+		return fmt.Errorf("Inputs.IsMoving: %w", err)
+	}
+	if x.IsCrouching, err = readBool(r); err != nil {
+		return fmt.Errorf("Inputs.IsCrouching: %w", err)
+	}
+	if err = x.MoveDirection.UnmarshalMono(r, version); err != nil {
+		return fmt.Errorf("Inputs.MoveDirection: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*Item)(nil)
+var _ MonoUnmarshaler = (*Item)(nil)
+
+func (x *Item) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.ID)
+	writeString(buf, x.Name)
+	writeBool(buf, x.Activated)
+	if err := x.Transforms.MarshalMono(buf, version); err != nil {
+		return fmt.Errorf("Item.Transforms: %w", err)
+	}
+	return nil
+}
+
+func (x *Item) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.ID, err = readInt32(r); err != nil {
+		return fmt.Errorf("Item.ID: %w", err)
+	}
+	if x.Name, err = readString(r); err != nil {
+		return fmt.Errorf("Item.Name: %w", err)
+	}
+	if x.Activated, err = readBool(r); err != nil {
+		return fmt.Errorf("Item.Activated: %w", err)
+	}
+	if err = x.Transforms.UnmarshalMono(r, version); err != nil {
+		return fmt.Errorf("Item.Transforms: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*JoinLobbyByCodePacket)(nil)
+var _ MonoUnmarshaler = (*JoinLobbyByCodePacket)(nil)
+
+func (x *JoinLobbyByCodePacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	if len(x.Code) > 16 {
+		return fmt.Errorf("JoinLobbyByCodePacket.Code: string length %d over maximum of 16", len(x.Code))
+	}
+	writeString(buf, x.Code)
+	if len(x.Password) > 64 {
+		return fmt.Errorf("JoinLobbyByCodePacket.Password: string length %d over maximum of 64", len(x.Password))
+	}
+	writeString(buf, x.Password)
+	return nil
+}
+
+func (x *JoinLobbyByCodePacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.Code, err = readStringMax(r, 16); err != nil {
+		return fmt.Errorf("JoinLobbyByCodePacket.Code: %w", err)
+	}
+	if x.Password, err = readStringMax(r, 64); err != nil {
+		return fmt.Errorf("JoinLobbyByCodePacket.Password: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*JoinLobbyPacket)(nil)
+var _ MonoUnmarshaler = (*JoinLobbyPacket)(nil)
+
+func (x *JoinLobbyPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.LobbyID)
+	if len(x.Password) > 64 {
+		return fmt.Errorf("JoinLobbyPacket.Password: string length %d over maximum of 64", len(x.Password))
+	}
+	writeString(buf, x.Password)
+	return nil
+}
+
+func (x *JoinLobbyPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.LobbyID, err = readInt32(r); err != nil {
+		return fmt.Errorf("JoinLobbyPacket.LobbyID: %w", err)
+	}
+	if x.Password, err = readStringMax(r, 64); err != nil {
+		return fmt.Errorf("JoinLobbyPacket.Password: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*KickPlayerPacket)(nil)
+var _ MonoUnmarshaler = (*KickPlayerPacket)(nil)
+
+func (x *KickPlayerPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.PlayerID)
+	if len(x.Reason) > 128 {
+		return fmt.Errorf("KickPlayerPacket.Reason: string length %d over maximum of 128", len(x.Reason))
+	}
+	writeString(buf, x.Reason)
+	return nil
+}
+
+func (x *KickPlayerPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.PlayerID, err = readInt32(r); err != nil {
+		return fmt.Errorf("KickPlayerPacket.PlayerID: %w", err)
+	}
+	if x.Reason, err = readStringMax(r, 128); err != nil {
+		return fmt.Errorf("KickPlayerPacket.Reason: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*KickedPacket)(nil)
+var _ MonoUnmarshaler = (*KickedPacket)(nil)
+
+func (x *KickedPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.LobbyID)
+	if len(x.Reason) > 128 {
+		return fmt.Errorf("KickedPacket.Reason: string length %d over maximum of 128", len(x.Reason))
+	}
+	writeString(buf, x.Reason)
+	writeBool(buf, x.Banned)
+	return nil
+}
+
+func (x *KickedPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.LobbyID, err = readInt32(r); err != nil {
+		return fmt.Errorf("KickedPacket.LobbyID: %w", err)
+	}
+	if x.Reason, err = readStringMax(r, 128); err != nil {
+		return fmt.Errorf("KickedPacket.Reason: %w", err)
+	}
+	if x.Banned, err = readBool(r); err != nil {
+		return fmt.Errorf("KickedPacket.Banned: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*LobbyListCursor)(nil)
+var _ MonoUnmarshaler = (*LobbyListCursor)(nil)
+
+func (x *LobbyListCursor) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt64(buf, x.Key)
+	writeInt32(buf, x.ID)
+	return nil
+}
+
+func (x *LobbyListCursor) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.Key, err = readInt64(r); err != nil {
+		return fmt.Errorf("LobbyListCursor.Key: %w", err)
+	}
+	if x.ID, err = readInt32(r); err != nil {
+		return fmt.Errorf("LobbyListCursor.ID: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*LobbyListEntry)(nil)
+var _ MonoUnmarshaler = (*LobbyListEntry)(nil)
+
+func (x *LobbyListEntry) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.ID)
+	writeString(buf, x.Name)
+	writeBool(buf, x.PasswordProtected)
+	writeInt32(buf, x.Players)
+	writeInt32(buf, x.MaxPlayers)
+	if version >= 5 {
+		writeString(buf, x.MapName)
+	}
+	if version >= 5 {
+		writeBool(buf, x.Started)
+	}
+	return nil
+}
+
+func (x *LobbyListEntry) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.ID, err = readInt32(r); err != nil {
+		return fmt.Errorf("LobbyListEntry.ID: %w", err)
+	}
+	if x.Name, err = readString(r); err != nil {
+		return fmt.Errorf("LobbyListEntry.Name: %w", err)
+	}
+	if x.PasswordProtected, err = readBool(r); err != nil {
+		return fmt.Errorf("LobbyListEntry.PasswordProtected: %w", err)
+	}
+	if x.Players, err = readInt32(r); err != nil {
+		return fmt.Errorf("LobbyListEntry.Players: %w", err)
+	}
+	if x.MaxPlayers, err = readInt32(r); err != nil {
+		return fmt.Errorf("LobbyListEntry.MaxPlayers: %w", err)
+	}
+	if version >= 5 {
+		if x.MapName, err = readString(r); err != nil {
+			return fmt.Errorf("LobbyListEntry.MapName: %w", err)
+		}
+	}
+	if version >= 5 {
+		if x.Started, err = readBool(r); err != nil {
+			return fmt.Errorf("LobbyListEntry.Started: %w", err)
+		}
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*LobbyListPacket)(nil)
+var _ MonoUnmarshaler = (*LobbyListPacket)(nil)
+
+func (x *LobbyListPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, int32(len(x.Lobbies)))
+	for i0 := range x.Lobbies {
+		if err := x.Lobbies[i0].MarshalMono(buf, version); err != nil {
+			return fmt.Errorf("LobbyListPacket.Lobbies[]: %w", err)
+		}
+	}
+	if version >= 5 {
+		writeInt32(buf, x.Total)
+	}
+	if version >= 5 {
+		if x.Next == nil {
+			writeBool(buf, false)
+		} else {
+			writeBool(buf, true)
+			if err := (*x.Next).MarshalMono(buf, version); err != nil {
+				return fmt.Errorf("LobbyListPacket.Next: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (x *LobbyListPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	{
+		n0, err := readLengthMax(r, 0)
+		if err != nil {
+			return fmt.Errorf("LobbyListPacket.Lobbies: %w", err)
+		}
+		x.Lobbies = make([]LobbyListEntry, n0)
+		for i0 := range x.Lobbies {
+			if err = x.Lobbies[i0].UnmarshalMono(r, version); err != nil {
+				return fmt.Errorf("LobbyListPacket.Lobbies[]: %w", err)
+			}
+		}
+	}
+	if version >= 5 {
+		if x.Total, err = readInt32(r); err != nil {
+			return fmt.Errorf("LobbyListPacket.Total: %w", err)
+		}
+	}
+	if version >= 5 {
+		{
+			p0, err := readPresence(r)
+			if err != nil {
+				return fmt.Errorf("LobbyListPacket.Next: %w", err)
+			}
+			x.Next = nil
+			if p0 {
+				x.Next = new(LobbyListCursor)
+				if err = (*x.Next).UnmarshalMono(r, version); err != nil {
+					return fmt.Errorf("LobbyListPacket.Next: %w", err)
+				}
+			}
+		}
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*LobbyListRequestPacket)(nil)
+var _ MonoUnmarshaler = (*LobbyListRequestPacket)(nil)
+
+func (x *LobbyListRequestPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeBool(buf, x.HideFull)
+	writeBool(buf, x.HidePasswordProtected)
+	writeBool(buf, x.HideStarted)
+	if len(x.Name) > 256 {
+		return fmt.Errorf("LobbyListRequestPacket.Name: string length %d over maximum of 256", len(x.Name))
+	}
+	writeString(buf, x.Name)
+	if len(x.MapName) > 64 {
+		return fmt.Errorf("LobbyListRequestPacket.MapName: string length %d over maximum of 64", len(x.MapName))
+	}
+	writeString(buf, x.MapName)
+	writeUint8(buf, x.Sort)
+	if x.After == nil {
+		writeBool(buf, false)
+	} else {
+		writeBool(buf, true)
+		if err := (*x.After).MarshalMono(buf, version); err != nil {
+			return fmt.Errorf("LobbyListRequestPacket.After: %w", err)
+		}
+	}
+	writeInt32(buf, x.Limit)
+	return nil
+}
+
+func (x *LobbyListRequestPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if r.Len() == 0 {
+		return nil
+	}
+	if x.HideFull, err = readBool(r); err != nil {
+		return fmt.Errorf("LobbyListRequestPacket.HideFull: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	if x.HidePasswordProtected, err = readBool(r); err != nil {
+		return fmt.Errorf("LobbyListRequestPacket.HidePasswordProtected: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	if x.HideStarted, err = readBool(r); err != nil {
+		return fmt.Errorf("LobbyListRequestPacket.HideStarted: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	if x.Name, err = readStringMax(r, 256); err != nil {
+		return fmt.Errorf("LobbyListRequestPacket.Name: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	if x.MapName, err = readStringMax(r, 64); err != nil {
+		return fmt.Errorf("LobbyListRequestPacket.MapName: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	if x.Sort, err = readUint8(r); err != nil {
+		return fmt.Errorf("LobbyListRequestPacket.Sort: %w", err)
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	{
+		p0, err := readPresence(r)
+		if err != nil {
+			return fmt.Errorf("LobbyListRequestPacket.After: %w", err)
+		}
+		x.After = nil
+		if p0 {
+			x.After = new(LobbyListCursor)
+			if err = (*x.After).UnmarshalMono(r, version); err != nil {
+				return fmt.Errorf("LobbyListRequestPacket.After: %w", err)
+			}
+		}
+	}
+	if r.Len() == 0 {
+		return nil
+	}
+	if x.Limit, err = readInt32(r); err != nil {
+		return fmt.Errorf("LobbyListRequestPacket.Limit: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*NetworkLobbyInfo)(nil)
+var _ MonoUnmarshaler = (*NetworkLobbyInfo)(nil)
+
+func (x *NetworkLobbyInfo) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeString(buf, x.LobbyName)
+	writeString(buf, x.MapName)
+	writeInt32(buf, x.Time)
+	writeInt32(buf, int32(len(x.Players)))
+	for i0 := range x.Players {
+		if err := x.Players[i0].MarshalMono(buf, version); err != nil {
+			return fmt.Errorf("NetworkLobbyInfo.Players[]: %w", err)
+		}
+	}
+	if version >= 3 {
+		writeInt32(buf, x.MaxPlayers)
+	}
+	if version >= 3 {
+		writeBool(buf, x.PasswordProtected)
+	}
+	if version >= 3 {
+		if len(x.Settings) > 32 {
+			return fmt.Errorf("NetworkLobbyInfo.Settings: length %d over maximum of 32", len(x.Settings))
+		}
+		writeInt32(buf, int32(len(x.Settings)))
+		for _, k0 := range slices.Sorted(maps.Keys(x.Settings)) {
+			v0 := x.Settings[k0]
+			writeString(buf, k0)
+			writeString(buf, v0)
+		}
+	}
+	if version >= 4 {
+		if len(x.JoinCode) > 16 {
+			return fmt.Errorf("NetworkLobbyInfo.JoinCode: string length %d over maximum of 16", len(x.JoinCode))
+		}
+		writeString(buf, x.JoinCode)
+	}
+	return nil
+}
+
+func (x *NetworkLobbyInfo) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.LobbyName, err = readString(r); err != nil {
+		return fmt.Errorf("NetworkLobbyInfo.LobbyName: %w", err)
+	}
+	if x.MapName, err = readString(r); err != nil {
+		return fmt.Errorf("NetworkLobbyInfo.MapName: %w", err)
+	}
+	if x.Time, err = readInt32(r); err != nil {
+		return fmt.Errorf("NetworkLobbyInfo.Time: %w", err)
+	}
+	{
+		n0, err := readLengthMax(r, 0)
+		if err != nil {
+			return fmt.Errorf("NetworkLobbyInfo.Players: %w", err)
+		}
+		x.Players = make([]NetworkPlayerInfo, n0)
+		for i0 := range x.Players {
+			if err = x.Players[i0].UnmarshalMono(r, version); err != nil {
+				return fmt.Errorf("NetworkLobbyInfo.Players[]: %w", err)
+			}
+		}
+	}
+	if version >= 3 {
+		if x.MaxPlayers, err = readInt32(r); err != nil {
+			return fmt.Errorf("NetworkLobbyInfo.MaxPlayers: %w", err)
+		}
+	}
+	if version >= 3 {
+		if x.PasswordProtected, err = readBool(r); err != nil {
+			return fmt.Errorf("NetworkLobbyInfo.PasswordProtected: %w", err)
+		}
+	}
+	if version >= 3 {
+		{
+			n0, err := readLengthMax(r, 32)
+			if err != nil {
+				return fmt.Errorf("NetworkLobbyInfo.Settings: %w", err)
+			}
+			x.Settings = make(map[string]string, n0)
+			for range n0 {
+				var k0 string
+				var v0 string
+				if k0, err = readString(r); err != nil {
+					return fmt.Errorf("NetworkLobbyInfo.Settings{key}: %w", err)
+				}
+				if v0, err = readString(r); err != nil {
+					return fmt.Errorf("NetworkLobbyInfo.Settings{}: %w", err)
+				}
+				x.Settings[k0] = v0
+			}
+		}
+	}
+	if version >= 4 {
+		if x.JoinCode, err = readStringMax(r, 16); err != nil {
+			return fmt.Errorf("NetworkLobbyInfo.JoinCode: %w", err)
+		}
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*NetworkPlayerInfo)(nil)
+var _ MonoUnmarshaler = (*NetworkPlayerInfo)(nil)
+
+func (x *NetworkPlayerInfo) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.ID)
+	writeString(buf, x.Name)
+	writeInt32(buf, int32(len(x.Cosmetics)))
+	for i0 := range x.Cosmetics {
+		writeString(buf, x.Cosmetics[i0])
+	}
+	writeString(buf, x.Skin)
+	writeBool(buf, x.IsMonster)
+	writeBool(buf, x.IsHost)
+	if version >= 2 {
+		writeBool(buf, x.Reconnecting)
+	}
+	return nil
+}
+
+func (x *NetworkPlayerInfo) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.ID, err = readInt32(r); err != nil {
+		return fmt.Errorf("NetworkPlayerInfo.ID: %w", err)
+	}
+	if x.Name, err = readString(r); err != nil {
+		return fmt.Errorf("NetworkPlayerInfo.Name: %w", err)
+	}
+	{
+		n0, err := readLengthMax(r, 0)
+		if err != nil {
+			return fmt.Errorf("NetworkPlayerInfo.Cosmetics: %w", err)
+		}
+		x.Cosmetics = make([]string, n0)
+		for i0 := range x.Cosmetics {
+			if x.Cosmetics[i0], err = readString(r); err != nil {
+				return fmt.Errorf("NetworkPlayerInfo.Cosmetics[]: %w", err)
+			}
+		}
+	}
+	if x.Skin, err = readString(r); err != nil {
+		return fmt.Errorf("NetworkPlayerInfo.Skin: %w", err)
+	}
+	if x.IsMonster, err = readBool(r); err != nil {
+		return fmt.Errorf("NetworkPlayerInfo.IsMonster: %w", err)
+	}
+	if x.IsHost, err = readBool(r); err != nil {
+		return fmt.Errorf("NetworkPlayerInfo.IsHost: %w", err)
+	}
+	if version >= 2 {
+		if x.Reconnecting, err = readBool(r); err != nil {
+			return fmt.Errorf("NetworkPlayerInfo.Reconnecting: %w", err)
+		}
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*PlayerData)(nil)
+var _ MonoUnmarshaler = (*PlayerData)(nil)
+
+func (x *PlayerData) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.PlayerID)
+	if err := x.Transforms.MarshalMono(buf, version); err != nil {
+		return fmt.Errorf("PlayerData.Transforms: %w", err)
+	}
+	if err := x.Inputs.MarshalMono(buf, version); err != nil {
+		return fmt.Errorf("PlayerData.Inputs: %w", err)
+	}
+	return nil
+}
+
+func (x *PlayerData) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.PlayerID, err = readInt32(r); err != nil {
+		return fmt.Errorf("PlayerData.PlayerID: %w", err)
+	}
+	if err = x.Transforms.UnmarshalMono(r, version); err != nil {
+		return fmt.Errorf("PlayerData.Transforms: %w", err)
+	}
+	if err = x.Inputs.UnmarshalMono(r, version); err != nil {
+		return fmt.Errorf("PlayerData.Inputs: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*PlayerTransformPacket)(nil)
+var _ MonoUnmarshaler = (*PlayerTransformPacket)(nil)
+
+func (x *PlayerTransformPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.ID)
+	if err := x.Transforms.MarshalMono(buf, version); err != nil {
+		return fmt.Errorf("PlayerTransformPacket.Transforms: %w", err)
+	}
+	if err := x.Inputs.MarshalMono(buf, version); err != nil {
+		return fmt.Errorf("PlayerTransformPacket.Inputs: %w", err)
+	}
+	return nil
+}
+
+func (x *PlayerTransformPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.ID, err = readInt32(r); err != nil {
+		return fmt.Errorf("PlayerTransformPacket.ID: %w", err)
+	}
+	if err = x.Transforms.UnmarshalMono(r, version); err != nil {
+		return fmt.Errorf("PlayerTransformPacket.Transforms: %w", err)
+	}
+	if err = x.Inputs.UnmarshalMono(r, version); err != nil {
+		return fmt.Errorf("PlayerTransformPacket.Inputs: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*PlayerTransformsPacket)(nil)
+var _ MonoUnmarshaler = (*PlayerTransformsPacket)(nil)
+
+func (x *PlayerTransformsPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, int32(len(x.Players)))
+	for i0 := range x.Players {
+		if err := x.Players[i0].MarshalMono(buf, version); err != nil {
+			return fmt.Errorf("PlayerTransformsPacket.Players[]: %w", err)
+		}
+	}
+	return nil
+}
+
+func (x *PlayerTransformsPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	{
+		n0, err := readLengthMax(r, 0)
+		if err != nil {
+			return fmt.Errorf("PlayerTransformsPacket.Players: %w", err)
+		}
+		x.Players = make([]PlayerData, n0)
+		for i0 := range x.Players {
+			if err = x.Players[i0].UnmarshalMono(r, version); err != nil {
+				return fmt.Errorf("PlayerTransformsPacket.Players[]: %w", err)
+			}
+		}
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*PunchIntroductionPacket)(nil)
+var _ MonoUnmarshaler = (*PunchIntroductionPacket)(nil)
+
+func (x *PunchIntroductionPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.PeerID)
+	writeUint32(buf, x.Token)
+	if len(x.PublicIP) > 45 {
+		return fmt.Errorf("PunchIntroductionPacket.PublicIP: string length %d over maximum of 45", len(x.PublicIP))
+	}
+	writeString(buf, x.PublicIP)
+	writeInt32(buf, x.PublicPort)
+	if len(x.PrivateIP) > 45 {
+		return fmt.Errorf("PunchIntroductionPacket.PrivateIP: string length %d over maximum of 45", len(x.PrivateIP))
+	}
+	writeString(buf, x.PrivateIP)
+	writeInt32(buf, x.PrivatePort)
+	writeInt32(buf, x.Delay)
+	return nil
+}
+
+func (x *PunchIntroductionPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.PeerID, err = readInt32(r); err != nil {
+		return fmt.Errorf("PunchIntroductionPacket.PeerID: %w", err)
+	}
+	if x.Token, err = readUint32(r); err != nil {
+		return fmt.Errorf("PunchIntroductionPacket.Token: %w", err)
+	}
+	if x.PublicIP, err = readStringMax(r, 45); err != nil {
+		return fmt.Errorf("PunchIntroductionPacket.PublicIP: %w", err)
+	}
+	if x.PublicPort, err = readInt32(r); err != nil {
+		return fmt.Errorf("PunchIntroductionPacket.PublicPort: %w", err)
+	}
+	if x.PrivateIP, err = readStringMax(r, 45); err != nil {
+		return fmt.Errorf("PunchIntroductionPacket.PrivateIP: %w", err)
+	}
+	if x.PrivatePort, err = readInt32(r); err != nil {
+		return fmt.Errorf("PunchIntroductionPacket.PrivatePort: %w", err)
+	}
+	if x.Delay, err = readInt32(r); err != nil {
+		return fmt.Errorf("PunchIntroductionPacket.Delay: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*PunchRequestPacket)(nil)
+var _ MonoUnmarshaler = (*PunchRequestPacket)(nil)
+
+func (x *PunchRequestPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.PeerID)
+	if len(x.PrivateIP) > 45 {
+		return fmt.Errorf("PunchRequestPacket.PrivateIP: string length %d over maximum of 45", len(x.PrivateIP))
+	}
+	writeString(buf, x.PrivateIP)
+	writeInt32(buf, x.PrivatePort)
+	return nil
+}
+
+func (x *PunchRequestPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.PeerID, err = readInt32(r); err != nil {
+		return fmt.Errorf("PunchRequestPacket.PeerID: %w", err)
+	}
+	if x.PrivateIP, err = readStringMax(r, 45); err != nil {
+		return fmt.Errorf("PunchRequestPacket.PrivateIP: %w", err)
+	}
+	if x.PrivatePort, err = readInt32(r); err != nil {
+		return fmt.Errorf("PunchRequestPacket.PrivatePort: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*PunchResultPacket)(nil)
+var _ MonoUnmarshaler = (*PunchResultPacket)(nil)
+
+func (x *PunchResultPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.PeerID)
+	writeUint32(buf, x.Token)
+	writeBool(buf, x.Success)
+	writeBool(buf, x.Relayed)
+	return nil
+}
+
+func (x *PunchResultPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.PeerID, err = readInt32(r); err != nil {
+		return fmt.Errorf("PunchResultPacket.PeerID: %w", err)
+	}
+	if x.Token, err = readUint32(r); err != nil {
+		return fmt.Errorf("PunchResultPacket.Token: %w", err)
+	}
+	if x.Success, err = readBool(r); err != nil {
+		return fmt.Errorf("PunchResultPacket.Success: %w", err)
+	}
+	if x.Relayed, err = readBool(r); err != nil {
+		return fmt.Errorf("PunchResultPacket.Relayed: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*RelayHeader)(nil)
+var _ MonoUnmarshaler = (*RelayHeader)(nil)
+
+func (x *RelayHeader) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.PeerID)
+	return nil
+}
+
+func (x *RelayHeader) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.PeerID, err = readInt32(r); err != nil {
+		return fmt.Errorf("RelayHeader.PeerID: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*SequencedHeader)(nil)
+var _ MonoUnmarshaler = (*SequencedHeader)(nil)
+
+func (x *SequencedHeader) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeUint16(buf, x.Sequence)
+	return nil
+}
+
+func (x *SequencedHeader) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.Sequence, err = readUint16(r); err != nil {
+		return fmt.Errorf("SequencedHeader.Sequence: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*TransferOwnershipPacket)(nil)
+var _ MonoUnmarshaler = (*TransferOwnershipPacket)(nil)
+
+func (x *TransferOwnershipPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, x.PlayerID)
+	return nil
+}
+
+func (x *TransferOwnershipPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.PlayerID, err = readInt32(r); err != nil {
+		return fmt.Errorf("TransferOwnershipPacket.PlayerID: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*Transforms)(nil)
+var _ MonoUnmarshaler = (*Transforms)(nil)
+
+func (x *Transforms) MarshalMono(buf *bytes.Buffer, version int32) error {
+	if err := x.Position.MarshalMono(buf, version); err != nil {
+		return fmt.Errorf("Transforms.Position: %w", err)
+	}
+	if err := x.Rotation.MarshalMono(buf, version); err != nil {
+		return fmt.Errorf("Transforms.Rotation: %w", err)
+	}
+	if err := x.RealVelocity.MarshalMono(buf, version); err != nil {
+		return fmt.Errorf("Transforms.RealVelocity: %w", err)
+	}
+	if err := x.RealAngularVelocity.MarshalMono(buf, version); err != nil {
+		return fmt.Errorf("Transforms.RealAngularVelocity: %w", err)
+	}
+	return nil
+}
+
+func (x *Transforms) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if err = x.Position.UnmarshalMono(r, version); err != nil {
+		return fmt.Errorf("Transforms.Position: %w", err)
+	}
+	if err = x.Rotation.UnmarshalMono(r, version); err != nil {
+		return fmt.Errorf("Transforms.Rotation: %w", err)
+	}
+	if err = x.RealVelocity.UnmarshalMono(r, version); err != nil {
+		return fmt.Errorf("Transforms.RealVelocity: %w", err)
+	}
+	if err = x.RealAngularVelocity.UnmarshalMono(r, version); err != nil {
+		return fmt.Errorf("Transforms.RealAngularVelocity: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*UpdateLobbyInfoPacket)(nil)
+var _ MonoUnmarshaler = (*UpdateLobbyInfoPacket)(nil)
+
+func (x *UpdateLobbyInfoPacket) MarshalMono(buf *bytes.Buffer, version int32) error {
+	if x.Name == nil {
+		writeBool(buf, false)
+	} else {
+		writeBool(buf, true)
+		if len((*x.Name)) > 256 {
+			return fmt.Errorf("UpdateLobbyInfoPacket.Name: string length %d over maximum of 256", len((*x.Name)))
+		}
+		writeString(buf, (*x.Name))
+	}
+	if x.MaxPlayers == nil {
+		writeBool(buf, false)
+	} else {
+		writeBool(buf, true)
+		writeInt32(buf, (*x.MaxPlayers))
+	}
+	if x.IsPasswordProtected == nil {
+		writeBool(buf, false)
+	} else {
+		writeBool(buf, true)
+		writeBool(buf, (*x.IsPasswordProtected))
+	}
+	if x.Password == nil {
+		writeBool(buf, false)
+	} else {
+		writeBool(buf, true)
+		if len((*x.Password)) > 64 {
+			return fmt.Errorf("UpdateLobbyInfoPacket.Password: string length %d over maximum of 64", len((*x.Password)))
+		}
+		writeString(buf, (*x.Password))
+	}
+	if x.MapName == nil {
+		writeBool(buf, false)
+	} else {
+		writeBool(buf, true)
+		if len((*x.MapName)) > 64 {
+			return fmt.Errorf("UpdateLobbyInfoPacket.MapName: string length %d over maximum of 64", len((*x.MapName)))
+		}
+		writeString(buf, (*x.MapName))
+	}
+	if len(x.Settings) > 32 {
+		return fmt.Errorf("UpdateLobbyInfoPacket.Settings: length %d over maximum of 32", len(x.Settings))
+	}
+	writeInt32(buf, int32(len(x.Settings)))
+	for _, k0 := range slices.Sorted(maps.Keys(x.Settings)) {
+		v0 := x.Settings[k0]
+		writeString(buf, k0)
+		writeString(buf, v0)
+	}
+	return nil
+}
+
+func (x *UpdateLobbyInfoPacket) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	{
+		p0, err := readPresence(r)
+		if err != nil {
+			return fmt.Errorf("UpdateLobbyInfoPacket.Name: %w", err)
+		}
+		x.Name = nil
+		if p0 {
+			x.Name = new(string)
+			if (*x.Name), err = readStringMax(r, 256); err != nil {
+				return fmt.Errorf("UpdateLobbyInfoPacket.Name: %w", err)
+			}
+		}
+	}
+	{
+		p0, err := readPresence(r)
+		if err != nil {
+			return fmt.Errorf("UpdateLobbyInfoPacket.MaxPlayers: %w", err)
+		}
+		x.MaxPlayers = nil
+		if p0 {
+			x.MaxPlayers = new(int32)
+			if (*x.MaxPlayers), err = readInt32(r); err != nil {
+				return fmt.Errorf("UpdateLobbyInfoPacket.MaxPlayers: %w", err)
+			}
+		}
+	}
+	{
+		p0, err := readPresence(r)
+		if err != nil {
+			return fmt.Errorf("UpdateLobbyInfoPacket.IsPasswordProtected: %w", err)
+		}
+		x.IsPasswordProtected = nil
+		if p0 {
+			x.IsPasswordProtected = new(bool)
+			if (*x.IsPasswordProtected), err = readBool(r); err != nil {
+				return fmt.Errorf("UpdateLobbyInfoPacket.IsPasswordProtected: %w", err)
+			}
+		}
+	}
+	{
+		p0, err := readPresence(r)
+		if err != nil {
+			return fmt.Errorf("UpdateLobbyInfoPacket.Password: %w", err)
+		}
+		x.Password = nil
+		if p0 {
+			x.Password = new(string)
+			if (*x.Password), err = readStringMax(r, 64); err != nil {
+				return fmt.Errorf("UpdateLobbyInfoPacket.Password: %w", err)
+			}
+		}
+	}
+	{
+		p0, err := readPresence(r)
+		if err != nil {
+			return fmt.Errorf("UpdateLobbyInfoPacket.MapName: %w", err)
+		}
+		x.MapName = nil
+		if p0 {
+			x.MapName = new(string)
+			if (*x.MapName), err = readStringMax(r, 64); err != nil {
+				return fmt.Errorf("UpdateLobbyInfoPacket.MapName: %w", err)
+			}
+		}
+	}
+	{
+		n0, err := readLengthMax(r, 32)
+		if err != nil {
+			return fmt.Errorf("UpdateLobbyInfoPacket.Settings: %w", err)
+		}
+		x.Settings = make(map[string]string, n0)
+		for range n0 {
+			var k0 string
+			var v0 string
+			if k0, err = readString(r); err != nil {
+				return fmt.Errorf("UpdateLobbyInfoPacket.Settings{key}: %w", err)
+			}
+			if v0, err = readString(r); err != nil {
+				return fmt.Errorf("UpdateLobbyInfoPacket.Settings{}: %w", err)
+			}
+			x.Settings[k0] = v0
+		}
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*Vector3)(nil)
+var _ MonoUnmarshaler = (*Vector3)(nil)
+
+func (x *Vector3) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeFloat32(buf, x.X)
+	writeFloat32(buf, x.Y)
+	writeFloat32(buf, x.Z)
+	return nil
+}
+
+func (x *Vector3) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	if x.X, err = readFloat32(r); err != nil {
+		return fmt.Errorf("Vector3.X: %w", err)
+	}
+	if x.Y, err = readFloat32(r); err != nil {
+		return fmt.Errorf("Vector3.Y: %w", err)
+	}
+	if x.Z, err = readFloat32(r); err != nil {
+		return fmt.Errorf("Vector3.Z: %w", err)
+	}
+	return err
+}
+
+var _ MonoMarshaler = (*WorldState)(nil)
+var _ MonoUnmarshaler = (*WorldState)(nil)
+
+func (x *WorldState) MarshalMono(buf *bytes.Buffer, version int32) error {
+	writeInt32(buf, int32(len(x.Items)))
+	for i0 := range x.Items {
+		if err := x.Items[i0].MarshalMono(buf, version); err != nil {
+			return fmt.Errorf("WorldState.Items[]: %w", err)
+		}
+	}
+	return nil
+}
+
+func (x *WorldState) UnmarshalMono(r *bytes.Reader, version int32) error {
+	var err error
+	{
+		n0, err := readLengthMax(r, 0)
+		if err != nil {
+			return fmt.Errorf("WorldState.Items: %w", err)
+		}
+		x.Items = make([]Item, n0)
+		for i0 := range x.Items {
+			if err = x.Items[i0].UnmarshalMono(r, version); err != nil {
+				return fmt.Errorf("WorldState.Items[]: %w", err)
+			}
+		}
+	}
+	return err
+}
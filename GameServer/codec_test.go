@@ -0,0 +1,181 @@
+package GameServer
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+// codecCases has a filled in value of every payload with a generated codec
+var codecCases = []monoPayload{
+	&Vector3{1, -2.5, 3},
+	&Inputs{IsSprinting: true, IsCrouching: true, MoveDirection: Vector3{0, 1, 0}},
+	&Transforms{Position: Vector3{1, 2, 3}, Rotation: Vector3{4, 5, 6}, RealVelocity: Vector3{7, 8, 9}, RealAngularVelocity: Vector3{-1, -2, -3}},
+	&PlayerData{PlayerID: 7, Transforms: Transforms{Position: Vector3{1, 1, 1}}, Inputs: Inputs{IsMoving: true}},
+	&Item{ID: 3, Name: "flashlight", Activated: true, Transforms: Transforms{Rotation: Vector3{0, 90, 0}}},
+	&WorldState{Items: []Item{{ID: 1, Name: "key"}, {ID: 2, Name: "żółć"}}},
+	&NetworkPlayerInfo{ID: 4, Name: "Zażółć", Cosmetics: []string{"hat"}, Skin: "red", IsMonster: true, IsHost: true, Reconnecting: true},
+	benchLobbyInfo(3),
+	&ErrorPacket{Message: "BANNED", Details: map[string]string{"reason": "spam", "expires": "never"}},
+	&HelloPacket{Name: "a", SteamID: "1", Version: "1.0.0", Capabilities: 3, PublicKey: bytes.Repeat([]byte{1}, 32), ProtocolVersion: ProtocolVersion, ResumeToken: []byte{9, 9}},
+	&IDAssignPacket{ID: 5, Capabilities: 1, PublicKey: []byte{2}, ProtocolVersion: 2, ResumeToken: []byte{3}, Resumed: true, UDPSecret: []byte{4}},
+	&ImHerePacket{ID: 6},
+	&BindChallengePacket{Cookie: []byte{1, 2, 3}},
+	&BindResponsePacket{ID: 6, Cookie: []byte{1}, MAC: []byte{2}},
+	&CreateLobbyPacket{Name: "lobby", MaxPlayers: 4, IsPasswordProtected: true, Password: "pw"},
+	&JoinLobbyPacket{LobbyID: 8, Password: "pw"},
+	&PlayerTransformPacket{ID: 1, Transforms: Transforms{Position: Vector3{1, 2, 3}}, Inputs: Inputs{IsMoving: true}},
+	benchTransforms(3),
+	&FragmentHeader{MessageID: 1, Index: 2, Count: 3},
+	&FragmentAckPacket{MessageID: 1, Index: 2},
+	&SequencedHeader{Sequence: 65535},
+	&AckPacket{Sequence: 1, AckBits: 0xF0F0F0F0},
+	&HeartbeatPacket{Sequence: 42},
+	&PunchRequestPacket{PeerID: 1, PrivateIP: "192.168.0.2", PrivatePort: 5000},
+	&PunchIntroductionPacket{PeerID: 1, Token: 2, PublicIP: "203.0.113.1", PublicPort: 3, PrivateIP: "10.0.0.1", PrivatePort: 4, Delay: 150},
+	&PunchResultPacket{PeerID: 1, Token: 2, Success: true, Relayed: true},
+	&RelayHeader{PeerID: 9},
+	&TransferOwnershipPacket{PlayerID: 2},
+	&UpdateLobbyInfoPacket{Name: ptr("new"), MaxPlayers: ptr(int32(6)), IsPasswordProtected: ptr(false), MapName: ptr(Maps.Grid), Settings: map[string]string{"k": "v"}},
+	&UpdateLobbyInfoPacket{Password: ptr("only the password"), Settings: map[string]string{}}, // empty maps are read back empty, not nil
+	&KickPlayerPacket{PlayerID: 3, Reason: "afk"},
+	&KickedPacket{LobbyID: 1, Reason: "afk", Banned: true},
+	&JoinLobbyByCodePacket{Code: "ABC-DEF", Password: "pw"},
+	&LobbyListRequestPacket{HideFull: true, HideStarted: true, Name: "room", MapName: Maps.Grid, Sort: LobbySortAge, After: &LobbyListCursor{Key: -3, ID: 7}, Limit: 10},
+	&LobbyListCursor{Key: -1, ID: 2},
+	&LobbyListEntry{ID: 1, Name: "room", PasswordProtected: true, Players: 2, MaxPlayers: 4, MapName: Maps.Lobby, Started: true},
+	&LobbyListPacket{Lobbies: []LobbyListEntry{{ID: 1, Name: "a"}, {ID: 2, Name: "b", Started: true}}, Total: 10, Next: &LobbyListCursor{Key: -1, ID: 2}},
+}
+
+func newOf(v monoPayload) monoPayload {
+	return reflect.New(reflect.TypeOf(v).Elem()).Interface().(monoPayload)
+}
+
+// TestCodecRoundTrip checks that the generated codecs write the same bytes as the reflection serializer for every version
+// and that both read them back the same
+func TestCodecRoundTrip(t *testing.T) {
+	for _, c := range codecCases {
+		for version := MinProtocolVersion; version <= ProtocolVersion; version++ {
+			t.Run(fmt.Sprintf("%T/v%d", c, version), func(t *testing.T) {
+				var generated, reflected bytes.Buffer
+				if err := c.MarshalMono(&generated, version); err != nil {
+					t.Fatal(err)
+				}
+				if err := serializeData(&reflected, c, version); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(generated.Bytes(), reflected.Bytes()) {
+					t.Fatalf("generated % x\nreflection % x", generated.Bytes(), reflected.Bytes())
+				}
+
+				fromGenerated, fromReflection := newOf(c), newOf(c)
+				r := bytes.NewReader(generated.Bytes())
+				if err := fromGenerated.UnmarshalMono(r, version); err != nil {
+					t.Fatal(err)
+				}
+				if r.Len() != 0 {
+					t.Fatalf("%d bytes left over", r.Len())
+				}
+				if err := deserializeData(bytes.NewReader(generated.Bytes()), fromReflection, version); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(fromGenerated, fromReflection) {
+					t.Fatalf("generated read %+v\nreflection read %+v", fromGenerated, fromReflection)
+				}
+				if version == ProtocolVersion && !reflect.DeepEqual(fromGenerated, c) {
+					t.Fatalf("read back %+v\nwant %+v", fromGenerated, c)
+				}
+			})
+		}
+	}
+}
+
+// TestCodecTruncated cuts every payload short. Both decoders have to agree, and payloads without optional fields have to fail.
+func TestCodecTruncated(t *testing.T) {
+	hasOptional := map[reflect.Type]bool{
+		reflect.TypeOf(&ErrorPacket{}):            true,
+		reflect.TypeOf(&HelloPacket{}):            true,
+		reflect.TypeOf(&IDAssignPacket{}):         true,
+		reflect.TypeOf(&LobbyListRequestPacket{}): true,
+	}
+	for _, c := range codecCases {
+		var buf bytes.Buffer
+		if err := c.MarshalMono(&buf, ProtocolVersion); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		for n := 0; n < len(data); n++ {
+			errGenerated := newOf(c).UnmarshalMono(bytes.NewReader(data[:n]), ProtocolVersion)
+			errReflection := deserializeData(bytes.NewReader(data[:n]), newOf(c), ProtocolVersion)
+			if (errGenerated == nil) != (errReflection == nil) {
+				t.Fatalf("%T cut to %d bytes: generated %v, reflection %v", c, n, errGenerated, errReflection)
+			}
+			if errGenerated == nil && !hasOptional[reflect.TypeOf(c)] {
+				t.Fatalf("%T cut to %d of %d bytes decoded without an error", c, n, len(data))
+			}
+		}
+	}
+}
+
+func TestCodecVersions(t *testing.T) {
+	t.Run("since field left out for older versions", func(t *testing.T) {
+		pl := &NetworkPlayerInfo{ID: 1, Name: "a", Reconnecting: true}
+		var v1, v2 bytes.Buffer
+		pl.MarshalMono(&v1, 1)
+		pl.MarshalMono(&v2, 2)
+		if v2.Len() != v1.Len()+1 {
+			t.Fatalf("v1 is %d bytes, v2 is %d, want one more for Reconnecting", v1.Len(), v2.Len())
+		}
+		var out NetworkPlayerInfo
+		if err := out.UnmarshalMono(bytes.NewReader(v1.Bytes()), 1); err != nil {
+			t.Fatal(err)
+		}
+		if out.Reconnecting {
+			t.Fatal("v1 decoded a field it doesn't have")
+		}
+	})
+	t.Run("lobby list keeps its old layout before v5", func(t *testing.T) {
+		list := &LobbyListPacket{Lobbies: []LobbyListEntry{{ID: 1, Name: "a", Players: 2, MaxPlayers: 4, MapName: "m", Started: true}}, Total: 9, Next: &LobbyListCursor{ID: 1}}
+		var buf bytes.Buffer
+		list.MarshalMono(&buf, 4)
+		old := Packet{}
+		old.AddInt(1)
+		old.AddInt(1)
+		old.AddString("a")
+		old.AddBool(false)
+		old.AddInt(2)
+		old.AddInt(4)
+		if !bytes.Equal(buf.Bytes(), old.Payload) {
+			t.Fatalf("got % x\nwant % x", buf.Bytes(), old.Payload)
+		}
+	})
+	t.Run("missing optional fields are zero", func(t *testing.T) {
+		old := &HelloPacket{Name: "a", SteamID: "1", Version: "1.0.0"}
+		var buf bytes.Buffer
+		writeString(&buf, old.Name)
+		writeString(&buf, old.SteamID)
+		writeString(&buf, old.Version)
+		for name, decode := range map[string]func(*HelloPacket) error{
+			"generated":  func(h *HelloPacket) error { return h.UnmarshalMono(bytes.NewReader(buf.Bytes()), ProtocolVersion) },
+			"reflection": func(h *HelloPacket) error { return deserializeData(bytes.NewReader(buf.Bytes()), h, ProtocolVersion) },
+		} {
+			var h HelloPacket
+			if err := decode(&h); err != nil {
+				t.Fatal(name, err)
+			}
+			if !reflect.DeepEqual(&h, old) {
+				t.Fatalf("%s read %+v, want %+v", name, h, old)
+			}
+		}
+	})
+	t.Run("over max is rejected", func(t *testing.T) {
+		var buf bytes.Buffer
+		writeString(&buf, string(make([]byte, 129)))
+		if err := new(HelloPacket).UnmarshalMono(bytes.NewReader(buf.Bytes()), ProtocolVersion); err == nil {
+			t.Fatal("129 byte name accepted")
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package GameServer
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	// FrameCompressed is set in the length field of frames whose payload is flate compressed.
+	// The length then counts the compressed bytes.
+	FrameCompressed uint32 = 1 << 31
+	// frameFlagsMask covers every bit of the length field that isn't length
+	frameFlagsMask = FrameCompressed | FrameEncrypted
+
+	// DefaultCompressionThreshold is the payload size below which compressing isn't worth it
+	DefaultCompressionThreshold = 256
+)
+
+var flateWriters = sync.Pool{
+	New: func() any {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	},
+}
+
+func compressPayload(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressPayload inflates a compressed payload, failing if it grows past limit bytes
+func decompressPayload(payload []byte, limit int32) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(payload))
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload : %w", err)
+	}
+	if int32(len(data)) > limit {
+		return nil, fmt.Errorf("decompressed payload exceeds maximum of %d", limit)
+	}
+	return data, nil
+}
+
+// inflate decompresses the payload of a compressed frame. Encrypted frames are left alone, they are inflated once opened.
+func (packet *Packet) inflate(limit int32) error {
+	if packet.frameFlags&FrameEncrypted != 0 || packet.frameFlags&FrameCompressed == 0 {
+		return nil
+	}
+	payload, err := decompressPayload(packet.Payload, limit)
+	if err != nil {
+		return err
+	}
+	packet.Payload = payload
+	packet.frameFlags &^= FrameCompressed
+	return nil
+}
+
+// splitLength separates the length field of a frame into the length and the frame flags
+func splitLength(field uint32) (int32, uint32) {
+	return int32(field &^ frameFlagsMask), field & frameFlagsMask
+}
@@ -0,0 +1,264 @@
+package GameServer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"MonophobiaServer/messages"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// EncryptionMode decides whether clients get an encrypted session
+type EncryptionMode int
+
+const (
+	EncryptionOff EncryptionMode = iota
+	// EncryptionOptional encrypts clients that support it and lets the others in as they are
+	EncryptionOptional
+	// EncryptionRequired turns away clients that can't do encryption
+	EncryptionRequired
+)
+
+const (
+	// FrameEncrypted is set in the length field of frames whose payload is sealed with the session keys
+	FrameEncrypted uint32 = 1 << 30
+
+	// explicitNonceSize is the counter sent in front of every encrypted UDP payload
+	explicitNonceSize = 8
+	replayWindowSize  = 64
+)
+
+func ParseEncryptionMode(s string) (EncryptionMode, error) {
+	switch s {
+	case "off":
+		return EncryptionOff, nil
+	case "optional":
+		return EncryptionOptional, nil
+	case "required":
+		return EncryptionRequired, nil
+	}
+	return EncryptionOff, fmt.Errorf("unknown encryption mode %q, use off, optional or required", s)
+}
+
+// cipherState is one direction of one transport. Nonces are a counter that is never reused with the same key.
+// TCP delivers in order so both sides count along, UDP frames carry their counter and a window filters replays.
+type cipherState struct {
+	mu       sync.Mutex
+	aead     cipher.AEAD
+	counter  uint64
+	explicit bool
+
+	// receiving side of UDP only
+	seen    bool
+	highest uint64
+	window  uint64 // bit n set: highest-n was received
+}
+
+func newCipherState(key []byte, explicit bool) (*cipherState, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &cipherState{aead: aead, explicit: explicit}, nil
+}
+
+func (c *cipherState) nonce(counter uint64) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	binary.LittleEndian.PutUint64(nonce[len(nonce)-8:], counter)
+	return nonce
+}
+
+// seal encrypts payload, aad is authenticated along with it but not sent
+func (c *cipherState) seal(aad, payload []byte) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counter := c.counter
+	c.counter++
+	var out []byte
+	if c.explicit {
+		out = binary.LittleEndian.AppendUint64(out, counter)
+	}
+	return c.aead.Seal(out, c.nonce(counter), payload, aad)
+}
+
+// overhead is how many bytes seal adds to a payload, none without a cipher
+func (c *cipherState) overhead() int {
+	if c == nil {
+		return 0
+	}
+	n := c.aead.Overhead()
+	if c.explicit {
+		n += explicitNonceSize
+	}
+	return n
+}
+
+func (c *cipherState) open(aad, data []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.explicit {
+		counter := c.counter
+		// a frame that fails is gone either way, the next one was sealed with the next counter
+		c.counter++
+		return c.aead.Open(nil, c.nonce(counter), data, aad)
+	}
+
+	if len(data) < explicitNonceSize {
+		return nil, fmt.Errorf("encrypted payload too short")
+	}
+	counter := binary.LittleEndian.Uint64(data[:explicitNonceSize])
+	if c.replayed(counter) {
+		return nil, fmt.Errorf("replayed nonce %d", counter)
+	}
+	payload, err := c.aead.Open(nil, c.nonce(counter), data[explicitNonceSize:], aad)
+	if err != nil {
+		return nil, err
+	}
+	// only authentic frames move the window, otherwise spoofed counters could push real packets out of it
+	c.markSeen(counter)
+	return payload, nil
+}
+
+func (c *cipherState) replayed(counter uint64) bool {
+	if !c.seen || counter > c.highest {
+		return false
+	}
+	diff := c.highest - counter
+	return diff >= replayWindowSize || c.window&(1<<diff) != 0
+}
+
+func (c *cipherState) markSeen(counter uint64) {
+	if !c.seen || counter > c.highest {
+		shift := counter - c.highest
+		if !c.seen || shift >= replayWindowSize {
+			c.window = 1
+		} else {
+			c.window = c.window<<shift | 1
+		}
+		c.highest = counter
+		c.seen = true
+		return
+	}
+	c.window |= 1 << (c.highest - counter)
+}
+
+// session holds the keys agreed on in the Hello/IDAssign exchange.
+// The exchange is not authenticated by any long term key, so it keeps out eavesdroppers and spoofed packets but not an active man in the middle.
+type session struct {
+	tcpSend, tcpRecv *cipherState
+	udpSend, udpRecv *cipherState
+}
+
+// newServerSession answers a client's X25519 public key with an ephemeral one of our own and derives the session keys from the shared secret
+func newServerSession(clientKey []byte) (*session, []byte, error) {
+	curve := ecdh.X25519()
+	peer, err := curve.NewPublicKey(clientKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid public key : %w", err)
+	}
+	priv, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, nil, err
+	}
+	serverKey := priv.PublicKey().Bytes()
+
+	// both public keys go into the salt so the keys are bound to this exchange
+	salt := append(append([]byte{}, clientKey...), serverKey...)
+	derive := func(info string, explicit bool) (*cipherState, error) {
+		key, err := hkdf.Key(sha256.New, shared, salt, info, 32)
+		if err != nil {
+			return nil, err
+		}
+		return newCipherState(key, explicit)
+	}
+	s := &session{}
+	for _, k := range []struct {
+		state    **cipherState
+		info     string
+		explicit bool
+	}{
+		{&s.tcpRecv, "monophobia c2s tcp", false},
+		{&s.tcpSend, "monophobia s2c tcp", false},
+		{&s.udpRecv, "monophobia c2s udp", true},
+		{&s.udpSend, "monophobia s2c udp", true},
+	} {
+		if *k.state, err = derive(k.info, k.explicit); err != nil {
+			return nil, nil, err
+		}
+	}
+	return s, serverKey, nil
+}
+
+// frameAAD is the part of the header that isn't covered by the length: Header and Flag
+func frameAAD(header messages.Header, flag messages.Flag) []byte {
+	return []byte{byte(header >> 8), byte(header), byte(flag)}
+}
+
+// openFrame decrypts a frame from the client and inflates it.
+// Once a client has a session every frame it sends has to be encrypted, anything else is counted as an authentication failure.
+func (s *GameServer) openFrame(client *Client, packet *Packet, udp bool) error {
+	encrypted := packet.frameFlags&FrameEncrypted != 0
+	if client.crypto == nil {
+		if encrypted {
+			s.authFailures.Add(1)
+			return fmt.Errorf("encrypted frame without a session")
+		}
+		return nil
+	}
+	if !encrypted {
+		s.authFailures.Add(1)
+		return fmt.Errorf("plaintext frame on an encrypted session")
+	}
+	state := client.crypto.tcpRecv
+	if udp {
+		state = client.crypto.udpRecv
+	}
+	payload, err := state.open(frameAAD(packet.Header, packet.Flag), packet.Payload)
+	if err != nil {
+		s.authFailures.Add(1)
+		return fmt.Errorf("authentication failed : %w", err)
+	}
+	packet.Payload = payload
+	packet.frameFlags &^= FrameEncrypted
+	return packet.inflate(s.maxFrameSize() - HeaderSize)
+}
+
+// AuthFailures is how many frames were dropped because they failed authentication
+func (s *GameServer) AuthFailures() uint64 {
+	return s.authFailures.Load()
+}
+
+// authFailureInterval is how often new authentication failures are logged
+const authFailureInterval = time.Minute
+
+// authFailureReporter logs how many frames failed authentication, once per interval and only if there were new ones
+func (s *GameServer) authFailureReporter() {
+	ticker := time.NewTicker(authFailureInterval)
+	defer ticker.Stop()
+	var reported uint64
+	for range ticker.C {
+		total := s.AuthFailures()
+		if total == reported {
+			continue
+		}
+		log.WithFields(log.Fields{"new": total - reported, "total": total, "interval": authFailureInterval}).Warn("Dropped frames that failed authentication")
+		reported = total
+	}
+}
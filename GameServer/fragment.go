@@ -0,0 +1,377 @@
+package GameServer
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"MonophobiaServer/messages"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultUDPMTU keeps datagrams under the usual internet path MTU once IP and UDP headers are added
+	DefaultUDPMTU = 1200
+	// fragmentHeaderSize is the encoded size of FragmentHeader
+	fragmentHeaderSize = 8
+	// MaxFragments caps how many pieces one message may be split into
+	MaxFragments = 256
+
+	// DefaultReassemblyTimeout is how long a fragmented message may take to arrive (or be acknowledged) completely
+	DefaultReassemblyTimeout  = 5 * time.Second
+	DefaultMaxReassemblyBytes = 8 * 1024 * 1024
+	// DefaultMaxReassemblyBytesPerSource keeps a single peer from taking up the whole reassembly buffer
+	DefaultMaxReassemblyBytesPerSource = 512 * 1024
+
+	fragmentResendInterval    = 200 * time.Millisecond
+	fragmentMaxPendingPerPeer = 32
+	fragmentJanitorInterval   = 100 * time.Millisecond
+)
+
+// FragmentHeader is the start of a Fragment packet's payload, the rest is the piece of the original frame.
+// Concatenating all pieces of a message in Index order gives the full frame of the original packet, header included.
+// On an encrypted session every Fragment datagram is sealed on its own and the frame they add up to is plaintext,
+// so a fragment is authenticated before it takes up room in the Reassembler.
+type FragmentHeader struct {
+	MessageID uint32
+	Index     uint16
+	Count     uint16
+}
+
+// FragmentAckPacket is sent back as Data/Response.FragmentReceived for every fragment that arrived
+type FragmentAckPacket struct {
+	MessageID uint32
+	Index     uint16
+}
+
+// Fragment splits the packet into Fragment datagrams, none of them longer than mtu
+func (packet *Packet) Fragment(messageID uint32, mtu int) ([][]byte, error) {
+	frame, err := packet.assembleMessage()
+	if err != nil {
+		return nil, err
+	}
+	pieces, err := fragmentFrame(frame, messageID, mtu, 0)
+	if err != nil {
+		return nil, err
+	}
+	datagrams := make([][]byte, len(pieces))
+	for i, piece := range pieces {
+		if datagrams[i], err = piece.assembleMessage(); err != nil {
+			return nil, err
+		}
+	}
+	return datagrams, nil
+}
+
+// fragmentFrame splits an assembled frame into Fragment packets that fit in mtu once overhead bytes of encryption are added
+func fragmentFrame(frame []byte, messageID uint32, mtu, overhead int) ([]*Packet, error) {
+	chunk := mtu - HeaderSize - fragmentHeaderSize - overhead
+	if chunk <= 0 {
+		return nil, fmt.Errorf("mtu %d too small for fragments", mtu)
+	}
+	count := (len(frame) + chunk - 1) / chunk
+	if count > MaxFragments {
+		return nil, fmt.Errorf("packet of %d bytes needs %d fragments, maximum is %d", len(frame), count, MaxFragments)
+	}
+
+	pieces := make([]*Packet, 0, count)
+	for i := 0; i < count; i++ {
+		end := min((i+1)*chunk, len(frame))
+		frag := &Packet{Header: messages.Fragment, Flag: messages.None}
+		if err := frag.AddToPayload(&FragmentHeader{messageID, uint16(i), uint16(count)}); err != nil {
+			return nil, err
+		}
+		frag.Payload = append(frag.Payload, frame[i*chunk:end]...)
+		pieces = append(pieces, frag)
+	}
+	return pieces, nil
+}
+
+type partialMessage struct {
+	pieces   [][]byte
+	received int
+	size     int
+	deadline time.Time
+}
+
+// Reassembler collects fragments per source until a message is complete.
+// Messages that don't complete before Timeout are evicted. MaxBytes bounds the memory all pending pieces may take,
+// MaxBytesPerSource what one source may take of it.
+type Reassembler struct {
+	mu                sync.Mutex
+	pending           map[string]map[uint32]*partialMessage
+	bytes             int
+	sourceBytes       map[string]int
+	Timeout           time.Duration
+	MaxBytes          int
+	MaxBytesPerSource int
+}
+
+func NewReassembler(timeout time.Duration, maxBytes int) *Reassembler {
+	return &Reassembler{
+		pending:           make(map[string]map[uint32]*partialMessage),
+		sourceBytes:       make(map[string]int),
+		Timeout:           timeout,
+		MaxBytes:          maxBytes,
+		MaxBytesPerSource: min(maxBytes, DefaultMaxReassemblyBytesPerSource),
+	}
+}
+
+// Add stores one fragment, data is copied. Once the last piece arrives the whole frame is returned.
+func (r *Reassembler) Add(source string, hdr FragmentHeader, data []byte) ([]byte, error) {
+	if hdr.Count == 0 || hdr.Count > MaxFragments || hdr.Index >= hdr.Count {
+		return nil, fmt.Errorf("invalid fragment %d/%d", hdr.Index, hdr.Count)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	messagesOfSource := r.pending[source]
+	msg, ok := messagesOfSource[hdr.MessageID]
+	if ok {
+		if int(hdr.Count) != len(msg.pieces) {
+			return nil, fmt.Errorf("fragment count changed from %d to %d", len(msg.pieces), hdr.Count)
+		}
+		if msg.pieces[hdr.Index] != nil {
+			// duplicate, the ack probably got lost
+			return nil, nil
+		}
+	}
+	// checked before a new message is set up, a rejected first piece must not hold on to one of the source's slots
+	if r.sourceBytes[source]+len(data) > r.MaxBytesPerSource {
+		return nil, fmt.Errorf("too many incomplete bytes from %s", source)
+	}
+	if r.bytes+len(data) > r.MaxBytes {
+		return nil, fmt.Errorf("reassembly buffer full")
+	}
+	if !ok {
+		if len(messagesOfSource) >= fragmentMaxPendingPerPeer {
+			return nil, fmt.Errorf("too many incomplete messages from %s", source)
+		}
+		if messagesOfSource == nil {
+			messagesOfSource = make(map[uint32]*partialMessage)
+			r.pending[source] = messagesOfSource
+		}
+		msg = &partialMessage{pieces: make([][]byte, hdr.Count), deadline: time.Now().Add(r.Timeout)}
+		messagesOfSource[hdr.MessageID] = msg
+	}
+
+	msg.pieces[hdr.Index] = append([]byte{}, data...)
+	msg.received++
+	msg.size += len(data)
+	r.bytes += len(data)
+	r.sourceBytes[source] += len(data)
+	if msg.received < len(msg.pieces) {
+		return nil, nil
+	}
+
+	frame := make([]byte, 0, msg.size)
+	for _, piece := range msg.pieces {
+		frame = append(frame, piece...)
+	}
+	r.remove(source, hdr.MessageID)
+	return frame, nil
+}
+
+func (r *Reassembler) remove(source string, id uint32) {
+	msg := r.pending[source][id]
+	r.bytes -= msg.size
+	r.sourceBytes[source] -= msg.size
+	delete(r.pending[source], id)
+	if len(r.pending[source]) == 0 {
+		delete(r.pending, source)
+		delete(r.sourceBytes, source)
+	}
+}
+
+// Evict drops every message whose deadline passed and returns how many were dropped
+func (r *Reassembler) Evict(now time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	evicted := 0
+	for source, messagesOfSource := range r.pending {
+		for id, msg := range messagesOfSource {
+			if now.After(msg.deadline) {
+				r.remove(source, id)
+				evicted++
+			}
+		}
+	}
+	return evicted
+}
+
+// Forget drops everything pending from a source, used when a client goes away
+func (r *Reassembler) Forget(source string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id := range r.pending[source] {
+		r.remove(source, id)
+	}
+}
+
+type outgoingMessage struct {
+	pieces    []*Packet
+	sealer    *cipherState // pieces are sealed every time they are sent, a resend with the old nonce would look like a replay
+	acked     []bool
+	remaining int
+	conn      *net.UDPConn
+	addr      *net.UDPAddr
+	lastSend  time.Time
+	deadline  time.Time
+}
+
+func (msg *outgoingMessage) sendPiece(i int) error {
+	datagram, err := msg.pieces[i].assembleFrame(0, msg.sealer, true)
+	if err != nil {
+		return err
+	}
+	_, err = msg.conn.WriteToUDP(datagram, msg.addr)
+	return err
+}
+
+// fragmentSender keeps sent fragments until they are acknowledged and resends the ones that weren't
+type fragmentSender struct {
+	mu      sync.Mutex
+	nextID  uint32
+	pending map[uint32]*outgoingMessage
+	Timeout time.Duration
+}
+
+func newFragmentSender(timeout time.Duration) *fragmentSender {
+	return &fragmentSender{pending: make(map[uint32]*outgoingMessage), Timeout: timeout}
+}
+
+// send fragments a plaintext frame, with a sealer every fragment is encrypted
+func (fs *fragmentSender) send(conn *net.UDPConn, addr *net.UDPAddr, frame []byte, mtu int, sealer *cipherState) error {
+	fs.mu.Lock()
+	fs.nextID++
+	id := fs.nextID
+	fs.mu.Unlock()
+
+	pieces, err := fragmentFrame(frame, id, mtu, sealer.overhead())
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	msg := &outgoingMessage{
+		pieces:    pieces,
+		sealer:    sealer,
+		acked:     make([]bool, len(pieces)),
+		remaining: len(pieces),
+		conn:      conn,
+		addr:      addr,
+		lastSend:  now,
+		deadline:  now.Add(fs.Timeout),
+	}
+	fs.mu.Lock()
+	fs.pending[id] = msg
+	fs.mu.Unlock()
+
+	for i := range pieces {
+		if err := msg.sendPiece(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *fragmentSender) ack(addr *net.UDPAddr, ack FragmentAckPacket) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	msg, ok := fs.pending[ack.MessageID]
+	if !ok || int(ack.Index) >= len(msg.acked) || msg.acked[ack.Index] || msg.addr.String() != addr.String() {
+		return
+	}
+	msg.acked[ack.Index] = true
+	msg.remaining--
+	if msg.remaining == 0 {
+		delete(fs.pending, ack.MessageID)
+	}
+}
+
+// resend sends unacknowledged fragments again and gives up on messages past their deadline
+func (fs *fragmentSender) resend(now time.Time) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for id, msg := range fs.pending {
+		if now.After(msg.deadline) {
+			log.WithFields(log.Fields{"MessageID": id, "Missing": msg.remaining, "Addr": msg.addr.String()}).Debug("Giving up on fragmented message")
+			delete(fs.pending, id)
+			continue
+		}
+		if now.Sub(msg.lastSend) < fragmentResendInterval {
+			continue
+		}
+		msg.lastSend = now
+		for i := range msg.pieces {
+			if !msg.acked[i] {
+				msg.sendPiece(i)
+			}
+		}
+	}
+}
+
+// handleFragment acknowledges a fragment and returns the reassembled frame once complete
+func (s *GameServer) handleFragment(client *Client, addr *net.UDPAddr, packet *Packet) ([]byte, error) {
+	var hdr FragmentHeader
+	if err := packet.ReadPayload(&hdr); err != nil {
+		return nil, err
+	}
+	if len(packet.Payload) < fragmentHeaderSize {
+		return nil, fmt.Errorf("fragment too short")
+	}
+	frame, err := s.reassembler.Add(addr.String(), hdr, packet.Payload[fragmentHeaderSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	ack := Packet{Header: messages.Data, Flag: messages.Response.FragmentReceived}
+	if err := ack.AddToPayload(&FragmentAckPacket{hdr.MessageID, hdr.Index}); err != nil {
+		return nil, err
+	}
+	if err := s.SendUDP(client, &ack); err != nil {
+		log.WithFields(log.Fields{"error": err.Error(), "PlayerID": client.ConnectedPlayer.ID}).Debug("Failed to acknowledge fragment")
+	}
+	return frame, nil
+}
+
+// SendUDP sends a packet to the client's bound UDP address, splitting it into fragments when it doesn't fit in one datagram
+func (s *GameServer) SendUDP(client *Client, packet *Packet) error {
+	if client.UDPAddr == nil {
+		return fmt.Errorf("client has no UDP address bound")
+	}
+	mtu := s.UDPMTU
+	if mtu <= 0 {
+		mtu = DefaultUDPMTU
+	}
+	var sealer *cipherState
+	if client.crypto != nil {
+		sealer = client.crypto.udpSend
+	}
+	frame, err := packet.assembleFrame(client.compressThreshold, sealer, client.fullFrameLength)
+	if err != nil {
+		return fmt.Errorf("failed to assemble packet : %w", err)
+	}
+	if len(frame) <= mtu {
+		_, err = client.udpConn.WriteToUDP(frame, client.UDPAddr)
+		return err
+	}
+	// the fragments get sealed instead of the frame
+	if frame, err = packet.assembleFrame(client.compressThreshold, nil, client.fullFrameLength); err != nil {
+		return fmt.Errorf("failed to assemble packet : %w", err)
+	}
+	return s.fragments.send(client.udpConn, client.UDPAddr, frame, mtu, sealer)
+}
+
+// fragmentJanitor evicts stale partial messages and resends unacknowledged fragments
+func (s *GameServer) fragmentJanitor() {
+	ticker := time.NewTicker(fragmentJanitorInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if evicted := s.reassembler.Evict(now); evicted > 0 {
+			log.WithField("Count", evicted).Debug("Evicted incomplete fragmented messages")
+		}
+		s.fragments.resend(now)
+	}
+}
@@ -0,0 +1,105 @@
+package GameServer
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"MonophobiaServer/messages"
+)
+
+func TestReassemblerPerSourceLimit(t *testing.T) {
+	r := NewReassembler(time.Minute, 4096)
+	r.MaxBytesPerSource = 1024
+	piece := make([]byte, 256)
+	// a flooder fills its own share with messages it never finishes
+	for id := uint32(0); id < 4; id++ {
+		if _, err := r.Add("flooder", FragmentHeader{MessageID: id, Index: 0, Count: 2}, piece); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.Add("flooder", FragmentHeader{MessageID: 9, Index: 0, Count: 2}, piece); err == nil {
+		t.Fatal("flooder went over its share")
+	}
+	if len(r.pending["flooder"]) != 4 {
+		t.Fatalf("a rejected fragment left a message behind, %d pending", len(r.pending["flooder"]))
+	}
+	// everyone else still gets through
+	for i := 0; i < 3; i++ {
+		source := fmt.Sprint("player", i)
+		r.Add(source, FragmentHeader{MessageID: 1, Index: 0, Count: 2}, piece)
+		frame, err := r.Add(source, FragmentHeader{MessageID: 1, Index: 1, Count: 2}, piece)
+		if err != nil || len(frame) != 2*len(piece) {
+			t.Fatalf("%s: %v", source, err)
+		}
+	}
+	r.Forget("flooder")
+	if r.bytes != 0 || len(r.sourceBytes) != 0 {
+		t.Fatalf("%d bytes still accounted for %v", r.bytes, r.sourceBytes)
+	}
+}
+
+// TestEncryptedFragments checks that fragments of an encrypted client are opened one by one before anything is done with them
+func TestEncryptedFragments(t *testing.T) {
+	s := newTestServer(t)
+	conn := NewPipeConnection("sealed", 8)
+	c := s.ConnectLocal(conn, "sealed")
+	udp, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	// acks go back to the socket itself
+	c.udpConn, c.UDPAddr = udp, udp.LocalAddr().(*net.UDPAddr)
+	key := make([]byte, 32)
+	clientSeal, _ := newCipherState(key, true)
+	recv, _ := newCipherState(key, true)
+	send, _ := newCipherState(key, true)
+	c.crypto = &session{udpRecv: recv, udpSend: send}
+
+	req := c.NewPacket(messages.Data, messages.Request.LobbyList)
+	if err := req.AddToPayload(&LobbyListRequestPacket{Name: strings.Repeat("x", 200)}); err != nil {
+		t.Fatal(err)
+	}
+	frame, err := req.assembleMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pieces, err := fragmentFrame(frame, 1, 100, clientSeal.overhead())
+	if err != nil || len(pieces) < 2 {
+		t.Fatalf("%d pieces: %v", len(pieces), err)
+	}
+	deliver := func(sealer *cipherState, piece *Packet) {
+		datagram, err := piece.assembleFrame(0, sealer, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in := &Packet{}
+		if err := in.DigestData(&datagram); err != nil {
+			t.Fatal(err)
+		}
+		s.handleUDPPacket(c, c.UDPAddr, in)
+	}
+
+	// a spoofer doesn't have the key
+	deliver(nil, pieces[0])
+	if len(s.reassembler.pending) != 0 || c.heartbeat.heard || s.AuthFailures() != 1 {
+		t.Fatalf("plaintext fragment was taken: %d pending, heard %v", len(s.reassembler.pending), c.heartbeat.heard)
+	}
+	for _, piece := range pieces {
+		deliver(clientSeal, piece)
+	}
+	if !c.heartbeat.heard || len(s.reassembler.pending) != 0 {
+		t.Fatalf("sealed fragments weren't reassembled, %d pending", len(s.reassembler.pending))
+	}
+	select {
+	case p := <-conn.Packets():
+		if p.Flag != messages.Response.LobbyList {
+			t.Fatalf("got flag %d, want the lobby list", p.Flag)
+		}
+	default:
+		t.Fatal("reassembled request wasn't handled")
+	}
+}
@@ -0,0 +1,70 @@
+package GameServer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"MonophobiaServer/messages"
+)
+
+const (
+	// HeaderSize is the size of Header (2), Flag (1) and FullMsgLen (4) on the wire
+	HeaderSize = 7
+	// DefaultMaxFrameSize is used when GameServer.MaxFrameSize is not set
+	DefaultMaxFrameSize = 64 * 1024
+	// FrameLengthVersion is the first protocol version that is sent frames whose length counts the header, like the ones it sends.
+	// Older clients are sent the payload length as before, and so is everyone until their Hello says otherwise.
+	FrameLengthVersion = 2
+)
+
+// FrameReader pulls exactly one packet at a time off a byte stream.
+// Incoming FullMsgLen is the length of the whole frame, header included. Outgoing it depends on FrameLengthVersion.
+// Compressed payloads are inflated, MaxFrameSize bounds the frame both before and after that.
+// Encrypted payloads are returned as they are, see GameServer.openFrame.
+type FrameReader struct {
+	reader       *bufio.Reader
+	header       [HeaderSize]byte
+	MaxFrameSize int32
+}
+
+func NewFrameReader(r io.Reader, maxFrameSize int32) *FrameReader {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	return &FrameReader{reader: bufio.NewReaderSize(r, 4096), MaxFrameSize: maxFrameSize}
+}
+
+// ReadPacket blocks until a full frame is available. Errors from the underlying
+// reader are returned as is, so io.EOF means the stream was closed cleanly.
+// A frame that is too short or too long is a fatal error, the stream can't be resynced after it.
+func (fr *FrameReader) ReadPacket() (*Packet, error) {
+	if _, err := io.ReadFull(fr.reader, fr.header[:]); err != nil {
+		return nil, err
+	}
+	packet := &Packet{}
+	packet.Header = messages.Header(binary.BigEndian.Uint16(fr.header[0:2]))
+	packet.Flag = messages.Flag(fr.header[2])
+	packet.FullMsgLen, packet.frameFlags = splitLength(binary.LittleEndian.Uint32(fr.header[3:7]))
+
+	if packet.FullMsgLen < HeaderSize {
+		return nil, fmt.Errorf("frame length %d shorter than header", packet.FullMsgLen)
+	}
+	if packet.FullMsgLen > fr.MaxFrameSize {
+		return nil, fmt.Errorf("frame length %d exceeds maximum of %d", packet.FullMsgLen, fr.MaxFrameSize)
+	}
+
+	packet.Payload = make([]byte, packet.FullMsgLen-HeaderSize)
+	if _, err := io.ReadFull(fr.reader, packet.Payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("failed reading packet payload %w", err)
+	}
+	if err := packet.inflate(fr.MaxFrameSize - HeaderSize); err != nil {
+		return nil, err
+	}
+	packet.payloadPointer = 0
+	return packet, nil
+}
@@ -0,0 +1,87 @@
+package GameServer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"MonophobiaServer/messages"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		payload   []byte
+		threshold int
+	}{
+		{"empty", nil, 0},
+		{"small", []byte("fifteen bytes!!"), 0},
+		{"compressed", bytes.Repeat([]byte("monophobia"), 200), 64},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &Packet{Header: messages.Data, Flag: messages.Post.ChatMessage, Payload: tc.payload}
+			frame, err := out.assembleFrame(tc.threshold, nil, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			length, _ := splitLength(binary.LittleEndian.Uint32(frame[3:7]))
+			if int(length) != len(frame) {
+				t.Fatalf("length field %d, frame is %d bytes", length, len(frame))
+			}
+
+			// two frames back to back, the reader must stop exactly at the end of the first
+			fr := NewFrameReader(bytes.NewReader(append(append([]byte{}, frame...), frame...)), 0)
+			for i := 0; i < 2; i++ {
+				in, err := fr.ReadPacket()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if in.Header != out.Header || in.Flag != out.Flag || !bytes.Equal(in.Payload, tc.payload) {
+					t.Fatalf("frame %d read back as %v/%v with %d payload bytes, want %d", i, in.Header, in.Flag, len(in.Payload), len(tc.payload))
+				}
+			}
+
+			var digested Packet
+			if err := digested.DigestData(&frame); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(digested.Payload, tc.payload) {
+				t.Fatalf("DigestData returned %d payload bytes, want %d", len(digested.Payload), len(tc.payload))
+			}
+		})
+	}
+}
+
+// TestLegacyFrameLength checks that clients from before FrameLengthVersion are still sent the payload length
+func TestLegacyFrameLength(t *testing.T) {
+	s := newTestServer(t)
+	conn, server := net.Pipe()
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	go s.handleConnection(server)
+
+	writeFrame(t, conn, messages.Hello, messages.None, &HelloPacket{Name: "old", SteamID: "1", Version: s.GameVersion})
+	header := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Fatal(err)
+	}
+	if messages.Flag(header[2]) != messages.Response.IDAssign {
+		t.Fatalf("got flag %d, want IDAssign", header[2])
+	}
+	length, _ := splitLength(binary.LittleEndian.Uint32(header[3:7]))
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		t.Fatal(err)
+	}
+	in := Packet{Payload: payload, Version: 1}
+	var id IDAssignPacket
+	if err := in.ReadPayload(&id); err != nil {
+		t.Fatal(err)
+	}
+	if id.ID < 0 {
+		t.Fatalf("unexpected IDAssign %+v", id)
+	}
+}
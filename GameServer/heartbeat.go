@@ -0,0 +1,125 @@
+package GameServer
+
+import (
+	"sync"
+	"time"
+
+	"MonophobiaServer/messages"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultHeartbeatInterval   = 2 * time.Second
+	DefaultMaxMissedHeartbeats = 5
+	// HeartbeatVersion is the first protocol version that answers pings. Older clients are neither pinged nor timed out,
+	// and their own Echo gets the empty Echo/None answer it always got.
+	HeartbeatVersion = 2
+)
+
+// HeartbeatPacket is the payload of Echo pings and pongs, a pong carries the sequence of the ping it answers
+type HeartbeatPacket struct {
+	Sequence uint32
+}
+
+// heartbeat tracks the pings sent to one client.
+// Any frame from the client proves it is alive, pongs additionally give a round trip time.
+type heartbeat struct {
+	mu      sync.Mutex
+	seq     uint32
+	pending map[uint32]time.Time
+	heard   bool // something arrived since the last ping
+	missed  int
+	rtt     time.Duration
+}
+
+func newHeartbeat() *heartbeat {
+	return &heartbeat{pending: make(map[uint32]time.Time)}
+}
+
+// ping counts the previous interval as missed if nothing arrived in it and returns the sequence for the next ping
+func (h *heartbeat) ping(now time.Time, maxPending int) (uint32, int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.heard && len(h.pending) > 0 {
+		h.missed++
+	}
+	h.heard = false
+	h.seq++
+	h.pending[h.seq] = now
+	// pongs for pings this old won't be waited for anymore
+	for seq := range h.pending {
+		if h.seq-seq >= uint32(maxPending) {
+			delete(h.pending, seq)
+		}
+	}
+	return h.seq, h.missed
+}
+
+// alive is called for every frame the client sends
+func (h *heartbeat) alive() {
+	h.mu.Lock()
+	h.heard = true
+	h.missed = 0
+	h.mu.Unlock()
+}
+
+// pong takes a round trip sample, smoothed like TCP does (1/8 of the new sample)
+func (h *heartbeat) pong(seq uint32, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sent, ok := h.pending[seq]
+	if !ok {
+		return
+	}
+	delete(h.pending, seq)
+	sample := now.Sub(sent)
+	if h.rtt == 0 {
+		h.rtt = sample
+	} else {
+		h.rtt += (sample - h.rtt) / 8
+	}
+}
+
+// RTT is the smoothed round trip time measured with heartbeats, 0 until the first pong arrived
+func (c *Client) RTT() time.Duration {
+	c.heartbeat.mu.Lock()
+	defer c.heartbeat.mu.Unlock()
+	return c.heartbeat.rtt
+}
+
+// heartbeatLoop pings the client every interval until done is closed.
+// After maxMissed intervals without hearing from it, the client is disconnected, handleConnection then cleans up as for any other disconnect.
+func (s *GameServer) heartbeatLoop(c *Client, done <-chan struct{}) {
+	interval := s.HeartbeatInterval
+	if interval <= 0 {
+		interval = DefaultHeartbeatInterval
+	}
+	maxMissed := s.MaxMissedHeartbeats
+	if maxMissed <= 0 {
+		maxMissed = DefaultMaxMissedHeartbeats
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			seq, missed := c.heartbeat.ping(now, maxMissed)
+			if missed >= maxMissed {
+				log.WithFields(log.Fields{"IP": c.IP, "Missed": missed}).Debug("Client stopped answering heartbeats")
+				c.Disconnect("TIMEOUT")
+				return
+			}
+			pac := c.NewPacket(messages.Echo, messages.Heartbeat.Ping)
+			if err := pac.AddToPayload(&HeartbeatPacket{seq}); err != nil {
+				log.WithField("error", err.Error()).Error("Failed to add heartbeat to packet")
+				return
+			}
+			if err := c.SendUnreliable(&pac); err != nil {
+				log.WithFields(log.Fields{"IP": c.IP, "error": err.Error()}).Debug("Failed to send heartbeat")
+			}
+		}
+	}
+}
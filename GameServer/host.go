@@ -0,0 +1,93 @@
+package GameServer
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HostMigration decides who owns a lobby after its owner left
+type HostMigration int
+
+const (
+	// HostLongestPresent picks the player who joined the lobby first
+	HostLongestPresent HostMigration = iota
+	// HostLowestRTT picks the player with the best connection, players without a measured RTT come last
+	HostLowestRTT
+)
+
+func ParseHostMigration(s string) (HostMigration, error) {
+	switch s {
+	case "longest":
+		return HostLongestPresent, nil
+	case "rtt":
+		return HostLowestRTT, nil
+	}
+	return HostLongestPresent, fmt.Errorf("unknown host migration policy %q, use longest or rtt", s)
+}
+
+// TransferOwnershipPacket hands the lobby to another player of it, only the owner may send it
+type TransferOwnershipPacket struct {
+	PlayerID int32
+}
+
+// setOwner makes pl the owner, IsHost follows along. Players get to know with the next BroadcastInfo.
+func (l *Lobby) setOwner(pl *Player) {
+	if l.Owner != nil {
+		l.Owner.IsHost = false
+	}
+	l.Owner = pl
+	pl.IsHost = true
+}
+
+// nextOwner picks who takes over from leaving by the lobby's policy. Players that are reconnecting are only picked if there is no one else.
+func (l *Lobby) nextOwner(leaving *Player) *Player {
+	var best *Player
+	better := func(pl *Player) bool {
+		if best == nil {
+			return true
+		}
+		if best.Reconnecting != pl.Reconnecting {
+			return best.Reconnecting
+		}
+		if l.HostMigration != HostLowestRTT {
+			// Players are in join order
+			return false
+		}
+		bestRTT, rtt := best.NetworkClient.RTT(), pl.NetworkClient.RTT()
+		return rtt != 0 && (bestRTT == 0 || rtt < bestRTT)
+	}
+	for _, pl := range l.playerList() {
+		if pl != leaving && better(pl) {
+			best = pl
+		}
+	}
+	return best
+}
+
+// migrateOwner moves the lobby away from an owner that left or whose connection dropped, returns false if there is no one to take over
+func (l *Lobby) migrateOwner(leaving *Player) bool {
+	next := l.nextOwner(leaving)
+	if next == nil {
+		return false
+	}
+	log.WithFields(log.Fields{"Lobby": l.Name, "Old": leaving.Name, "New": next.Name}).Debug("Lobby owner changed")
+	l.setOwner(next)
+	return true
+}
+
+// transferOwnership is the owner handing the lobby over, the returned error is the reason sent back to the client
+func (l *Lobby) transferOwnership(from *Player, to int32) error {
+	if l.Owner != from {
+		return fmt.Errorf("NOT_LOBBY_OWNER")
+	}
+	for _, pl := range l.playerList() {
+		if pl.ID == to && pl != from {
+			l.setOwner(pl)
+			log.WithFields(log.Fields{"Lobby": l.Name, "Old": from.Name, "New": pl.Name}).Debug("Lobby ownership transferred")
+			l.BroadcastInfo()
+			return nil
+		}
+	}
+	return fmt.Errorf("PLAYER_NOT_FOUND")
+}
@@ -0,0 +1,70 @@
+package GameServer
+
+import (
+	"math/rand/v2"
+	"strings"
+)
+
+const (
+	joinCodeLength = 6
+	// joinCodeAlphabet leaves out 0/O and 1/I so codes survive being read out loud
+	joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// JoinLobbyByCodePacket joins the lobby with the given JoinCode, the same checks as for JoinLobby apply
+type JoinLobbyByCodePacket struct {
+	Code     string `mono:"max=16"`
+	Password string `mono:"max=64"`
+}
+
+// newLobbyID picks an ID no open lobby has, it has nothing to do with the owner's player ID
+func (s *GameServer) newLobbyID() int32 {
+	for {
+		id := rand.Int32()
+		if s.lobbyByID(id) == nil {
+			return id
+		}
+	}
+}
+
+// newJoinCode picks a code no open lobby has
+func (s *GameServer) newJoinCode() string {
+	code := make([]byte, joinCodeLength)
+	for {
+		for i := range code {
+			code[i] = joinCodeAlphabet[rand.IntN(len(joinCodeAlphabet))]
+		}
+		if s.lobbyByCode(string(code)) == nil {
+			return string(code)
+		}
+	}
+}
+
+// normalizeJoinCode accepts codes typed in lower case or with spaces and dashes in between
+func normalizeJoinCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
+
+func (s *GameServer) lobbyByID(id int32) *Lobby {
+	for _, lb := range s.Lobbies { // O(n) shouldn't be an issue, right?
+		if lb.ID == id {
+			return lb
+		}
+	}
+	return nil
+}
+
+func (s *GameServer) lobbyByCode(code string) *Lobby {
+	code = normalizeJoinCode(code)
+	for _, lb := range s.Lobbies {
+		if lb.JoinCode == code {
+			return lb
+		}
+	}
+	return nil
+}
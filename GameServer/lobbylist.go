@@ -0,0 +1,169 @@
+package GameServer
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultLobbyListPage is the page size when a request doesn't ask for one
+	defaultLobbyListPage = 50
+	maxLobbyListPage     = 100
+)
+
+// Orders of the lobby list, ties are broken by lobby ID so pages stay put while nothing changes
+const (
+	LobbySortPlayers    uint8 = iota // most players first
+	LobbySortAge                     // newest first
+	LobbySortPingRegion              // lobbies whose players have about the requester's ping first
+)
+
+// LobbyListRequestPacket is the payload of Request.LobbyList. Clients that predate it send nothing, which gets them every lobby unfiltered.
+type LobbyListRequestPacket struct {
+	HideFull              bool   `mono:"optional"`
+	HidePasswordProtected bool   `mono:"optional"`
+	HideStarted           bool   `mono:"optional"`
+	Name                  string `mono:"optional,max=256"` // case insensitive substring of the lobby name
+	MapName               string `mono:"optional,max=64"`
+	Sort                  uint8  `mono:"optional"`
+	// After is the Next of the previous page, nil for the first page
+	After *LobbyListCursor `mono:"optional"`
+	Limit int32            `mono:"optional"`
+}
+
+// LobbyListCursor is the sort key and ID of the last lobby of a page, the next page starts right after it.
+// Lobbies that open or close in between don't shift the pages like an offset would.
+type LobbyListCursor struct {
+	Key int64
+	ID  int32
+}
+
+// LobbyListEntry is one lobby of the list, the fields before the since ones are the layout of the list before it got paged
+type LobbyListEntry struct {
+	ID                int32
+	Name              string
+	PasswordProtected bool
+	Players           int32
+	MaxPlayers        int32
+	MapName           string `mono:"since=5"`
+	Started           bool   `mono:"since=5"`
+}
+
+// LobbyListPacket is the answer to Request.LobbyList.
+// Pages hold at most maxLobbyListPage lobbies, clients before protocol 5 can't page and get every lobby as they did before.
+type LobbyListPacket struct {
+	Lobbies []LobbyListEntry
+	// Total is how many lobbies matched the filters over all pages
+	Total int32 `mono:"since=5"`
+	// Next is the After for the next page, nil if this was the last one
+	Next *LobbyListCursor `mono:"since=5"`
+}
+
+func (req *LobbyListRequestPacket) matches(l *Lobby) bool {
+	switch {
+	case req.HideFull && l.playerCount() >= int(l.MaxPlayers):
+		return false
+	case req.HidePasswordProtected && l.PasswordProtected:
+		return false
+	case req.HideStarted && l.Started:
+		return false
+	case req.MapName != "" && l.Map != req.MapName:
+		return false
+	}
+	return req.Name == "" || strings.Contains(strings.ToLower(l.Name), strings.ToLower(req.Name))
+}
+
+// averageRTT of the players in the lobby, 0 if none was measured yet
+func (l *Lobby) averageRTT() time.Duration {
+	var sum time.Duration
+	n := 0
+	for _, pl := range l.playerList() {
+		if rtt := pl.NetworkClient.RTT(); rtt != 0 {
+			sum += rtt
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / time.Duration(n)
+}
+
+// lobbyList filters, sorts and pages the open lobbies for client, clients that can't page get all of them.
+// There are no regions as such, players with about the same ping to the server are most likely near each other, so that is what the ping region order goes by.
+func (s *GameServer) lobbyList(client *Client, req *LobbyListRequestPacket) *LobbyListPacket {
+	lobbies := make([]*Lobby, 0, len(s.Lobbies))
+	for _, l := range s.Lobbies {
+		if req.matches(l) {
+			lobbies = append(lobbies, l)
+		}
+	}
+
+	var key func(l *Lobby) int64
+	switch req.Sort {
+	case LobbySortAge:
+		key = func(l *Lobby) int64 { return -l.Created.UnixNano() }
+	case LobbySortPingRegion:
+		own := client.RTT()
+		rtts := make(map[*Lobby]time.Duration, len(lobbies))
+		for _, l := range lobbies {
+			rtts[l] = l.averageRTT()
+		}
+		key = func(l *Lobby) int64 {
+			if rtts[l] == 0 {
+				// nothing to go by, these come last
+				return int64(time.Hour)
+			}
+			return int64((rtts[l] - own).Abs())
+		}
+	default:
+		key = func(l *Lobby) int64 { return -int64(l.playerCount()) }
+	}
+	cursor := func(l *Lobby) LobbyListCursor { return LobbyListCursor{key(l), l.ID} }
+	compare := func(a, b LobbyListCursor) int {
+		return cmp.Or(cmp.Compare(a.Key, b.Key), cmp.Compare(a.ID, b.ID))
+	}
+	slices.SortFunc(lobbies, func(a, b *Lobby) int { return compare(cursor(a), cursor(b)) })
+
+	total := len(lobbies)
+	// paging came with protocol 5
+	if client.ProtocolVersion < 5 {
+		return lobbyListPage(lobbies, total)
+	}
+	if req.After != nil {
+		start, _ := slices.BinarySearchFunc(lobbies, *req.After, func(l *Lobby, after LobbyListCursor) int {
+			return cmp.Or(compare(cursor(l), after), -1)
+		})
+		lobbies = lobbies[start:]
+	}
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultLobbyListPage
+	}
+	limit = min(limit, maxLobbyListPage)
+	if len(lobbies) <= limit {
+		return lobbyListPage(lobbies, total)
+	}
+	resp := lobbyListPage(lobbies[:limit], total)
+	next := cursor(lobbies[limit-1])
+	resp.Next = &next
+	return resp
+}
+
+func lobbyListPage(lobbies []*Lobby, total int) *LobbyListPacket {
+	resp := &LobbyListPacket{Lobbies: make([]LobbyListEntry, 0, len(lobbies)), Total: int32(total)}
+	for _, l := range lobbies {
+		resp.Lobbies = append(resp.Lobbies, LobbyListEntry{
+			ID:                l.ID,
+			Name:              l.Name,
+			PasswordProtected: l.PasswordProtected,
+			Players:           int32(l.playerCount()),
+			MaxPlayers:        l.MaxPlayers,
+			MapName:           l.Map,
+			Started:           l.Started,
+		})
+	}
+	return resp
+}
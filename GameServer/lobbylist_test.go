@@ -0,0 +1,54 @@
+package GameServer
+
+import (
+	"slices"
+	"testing"
+)
+
+func addListLobby(s *GameServer, id int32, players int) {
+	s.Lobbies = append(s.Lobbies, &Lobby{ID: id, Name: "room", MaxPlayers: 8, Players: make([]*Player, players)})
+}
+
+func listIDs(list *LobbyListPacket) []int32 {
+	ids := make([]int32, len(list.Lobbies))
+	for i, l := range list.Lobbies {
+		ids[i] = l.ID
+	}
+	return ids
+}
+
+// TestLobbyListPages checks that lobbies opening between two requests don't shift the next page
+func TestLobbyListPages(t *testing.T) {
+	s := newTestServer(t)
+	client := &Client{ProtocolVersion: ProtocolVersion}
+	for id := int32(1); id <= 5; id++ {
+		addListLobby(s, id, int(id))
+	}
+
+	first := s.lobbyList(client, &LobbyListRequestPacket{Limit: 2})
+	if got := listIDs(first); !slices.Equal(got, []int32{5, 4}) || first.Total != 5 || first.Next == nil {
+		t.Fatalf("first page %v, total %d, next %v", got, first.Total, first.Next)
+	}
+	// sorts in front of the page that was already sent
+	addListLobby(s, 6, 7)
+
+	second := s.lobbyList(client, &LobbyListRequestPacket{Limit: 2, After: first.Next})
+	if got := listIDs(second); !slices.Equal(got, []int32{3, 2}) || second.Next == nil {
+		t.Fatalf("second page %v, next %v", got, second.Next)
+	}
+	last := s.lobbyList(client, &LobbyListRequestPacket{Limit: 2, After: second.Next})
+	if got := listIDs(last); !slices.Equal(got, []int32{1}) || last.Next != nil {
+		t.Fatalf("last page %v, next %v", got, last.Next)
+	}
+}
+
+func TestLobbyListBeforePaging(t *testing.T) {
+	s := newTestServer(t)
+	for id := int32(1); id <= maxLobbyListPage+20; id++ {
+		addListLobby(s, id, 1)
+	}
+	list := s.lobbyList(&Client{ProtocolVersion: 4}, &LobbyListRequestPacket{})
+	if len(list.Lobbies) != len(s.Lobbies) || list.Next != nil {
+		t.Fatalf("got %d of %d lobbies, next %v", len(list.Lobbies), len(s.Lobbies), list.Next)
+	}
+}
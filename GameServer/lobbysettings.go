@@ -0,0 +1,112 @@
+package GameServer
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MinLobbyPlayers is the smallest MaxPlayers a lobby can be created or updated with
+	MinLobbyPlayers = 3
+	// MaxLobbyPlayers is the largest MaxPlayers an owner can update a lobby to, CreateLobby doesn't check it
+	MaxLobbyPlayers = 16
+	// limits of Lobby.Settings, the game decides what the keys mean
+	maxLobbySettings     = 32
+	maxLobbySettingKey   = 32
+	maxLobbySettingValue = 128
+)
+
+// UpdateLobbyInfoPacket changes the settings of a lobby that hasn't started, fields left out stay as they are
+type UpdateLobbyInfoPacket struct {
+	Name                *string `mono:"max=256"` // same limit as CreateLobbyPacket.Name
+	MaxPlayers          *int32
+	IsPasswordProtected *bool
+	// Password is only looked at while the lobby is password protected
+	Password *string `mono:"max=64"`
+	MapName  *string `mono:"max=64"`
+	// Settings are merged into the lobby's, an empty value removes the key
+	Settings map[string]string `mono:"max=32"`
+}
+
+func knownMap(name string) bool {
+	return name == Maps.Lobby || name == Maps.Grid
+}
+
+// updateSettings applies an owner's UpdateLobbyInfo. Nothing is changed unless all of it is valid,
+// the returned error is the reason sent back to the client. Only what the update changes is checked,
+// so a lobby that CreateLobby let through with more players or an empty password can still be renamed.
+func (l *Lobby) updateSettings(from *Player, update *UpdateLobbyInfoPacket) error {
+	if l.Owner != from {
+		return fmt.Errorf("NOT_LOBBY_OWNER")
+	}
+	if l.Started {
+		return fmt.Errorf("LOBBY_STARTED")
+	}
+	if update.Name != nil && strings.TrimSpace(*update.Name) == "" {
+		return fmt.Errorf("INVALID_LOBBY_NAME")
+	}
+	maxPlayers, protected, password := l.MaxPlayers, l.PasswordProtected, l.Password
+	if update.MaxPlayers != nil {
+		maxPlayers = *update.MaxPlayers
+		switch {
+		case maxPlayers < MinLobbyPlayers || int(maxPlayers) < l.playerCount():
+			return fmt.Errorf("MAX_PLAYERS_TOO_SMALL")
+		case maxPlayers > MaxLobbyPlayers:
+			return fmt.Errorf("MAX_PLAYERS_TOO_LARGE")
+		}
+	}
+	if update.IsPasswordProtected != nil || update.Password != nil {
+		if update.IsPasswordProtected != nil {
+			protected = *update.IsPasswordProtected
+		}
+		if update.Password != nil {
+			password = *update.Password
+		}
+		if protected && password == "" {
+			return fmt.Errorf("PASSWORD_REQUIRED")
+		}
+	}
+	if update.MapName != nil && !knownMap(*update.MapName) {
+		return fmt.Errorf("MAP_NOT_FOUND")
+	}
+	added := 0
+	for key, value := range update.Settings {
+		if key == "" || len(key) > maxLobbySettingKey || len(value) > maxLobbySettingValue {
+			return fmt.Errorf("INVALID_SETTING")
+		}
+		if _, ok := l.Settings[key]; !ok && value != "" {
+			added++
+		}
+	}
+	if len(l.Settings)+added > maxLobbySettings {
+		return fmt.Errorf("TOO_MANY_SETTINGS")
+	}
+
+	if update.Name != nil {
+		l.Name = *update.Name
+	}
+	l.MaxPlayers = maxPlayers
+	l.PasswordProtected = protected
+	l.Password = password
+	if !l.PasswordProtected {
+		l.Password = ""
+	}
+	if update.MapName != nil {
+		l.Map = *update.MapName
+	}
+	for key, value := range update.Settings {
+		if value == "" {
+			delete(l.Settings, key)
+			continue
+		}
+		if l.Settings == nil {
+			l.Settings = make(map[string]string)
+		}
+		l.Settings[key] = value
+	}
+	log.WithFields(log.Fields{"Lobby": l.Name, "Max_players": l.MaxPlayers, "Password": l.PasswordProtected, "Map": l.Map, "Settings": len(l.Settings)}).Debug("Lobby settings updated")
+	l.BroadcastInfo()
+	return nil
+}
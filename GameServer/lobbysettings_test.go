@@ -0,0 +1,48 @@
+package GameServer
+
+import "testing"
+
+func TestUpdateSettingsLimits(t *testing.T) {
+	owner := &Player{ID: 1}
+	cases := []struct {
+		name   string
+		lobby  *Lobby
+		update UpdateLobbyInfoPacket
+		want   string
+	}{
+		{"below the floor", &Lobby{MaxPlayers: 4}, UpdateLobbyInfoPacket{MaxPlayers: ptr(int32(MinLobbyPlayers - 1))}, "MAX_PLAYERS_TOO_SMALL"},
+		{"below the player count", &Lobby{MaxPlayers: 4, Players: make([]*Player, 4)}, UpdateLobbyInfoPacket{MaxPlayers: ptr(int32(3))}, "MAX_PLAYERS_TOO_SMALL"},
+		{"over the ceiling", &Lobby{MaxPlayers: 4}, UpdateLobbyInfoPacket{MaxPlayers: ptr(int32(MaxLobbyPlayers + 1))}, "MAX_PLAYERS_TOO_LARGE"},
+		{"protected without a password", &Lobby{MaxPlayers: 4}, UpdateLobbyInfoPacket{IsPasswordProtected: ptr(true)}, "PASSWORD_REQUIRED"},
+		{"protected with an empty password", &Lobby{MaxPlayers: 4}, UpdateLobbyInfoPacket{IsPasswordProtected: ptr(true), Password: ptr("")}, "PASSWORD_REQUIRED"},
+		{"password cleared while protected", &Lobby{MaxPlayers: 4, PasswordProtected: true, Password: "pw"}, UpdateLobbyInfoPacket{Password: ptr("")}, "PASSWORD_REQUIRED"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := c.lobby
+			l.Owner = owner
+			before := l.MaxPlayers
+			err := l.updateSettings(owner, &c.update)
+			if err == nil || err.Error() != c.want {
+				t.Fatalf("got %v, want %s", err, c.want)
+			}
+			if l.MaxPlayers != before {
+				t.Fatal("rejected update was applied")
+			}
+		})
+	}
+}
+
+// TestUpdateSettingsUnchangedLimits checks that only what an update changes is held to the limits
+func TestUpdateSettingsUnchangedLimits(t *testing.T) {
+	owner := &Player{ID: 1}
+	// CreateLobby lets both of these through
+	for _, l := range []*Lobby{
+		{Owner: owner, MaxPlayers: MaxLobbyPlayers * 2},
+		{Owner: owner, MaxPlayers: 4, PasswordProtected: true},
+	} {
+		if err := l.updateSettings(owner, &UpdateLobbyInfoPacket{Name: ptr("renamed")}); err != nil || l.Name != "renamed" {
+			t.Fatalf("renaming %+v: %v", l, err)
+		}
+	}
+}
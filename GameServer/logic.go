@@ -10,31 +10,51 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// playerList returns a copy of Players
+func (l *Lobby) playerList() []*Player {
+	l.playersMu.RLock()
+	defer l.playersMu.RUnlock()
+	return slices.Clone(l.Players)
+}
+
+func (l *Lobby) playerCount() int {
+	l.playersMu.RLock()
+	defer l.playersMu.RUnlock()
+	return len(l.Players)
+}
+
 func (l *Lobby) BroadcastInfo() {
-	pac := Packet{}
-	pac.Header = messages.Data
-	pac.Flag = messages.Response.LobbyInfo
-	if err := pac.AddToPayload(l.ToNetwork()); err != nil {
-		log.WithField("Error", err.Error()).Error("Adding lobby data to packet failed")
-	}
-	for _, pl := range l.Players {
-		pac.Send(*pl.NetworkClient.Conn)
+	pac := newVersionedPacket(messages.Data, messages.Response.LobbyInfo, l.ToNetwork())
+	for _, pl := range l.playerList() {
+		p, err := pac.forClient(pl.NetworkClient)
+		if err != nil {
+			log.WithField("Error", err.Error()).Error("Adding lobby data to packet failed")
+			return
+		}
+		if err := pl.NetworkClient.Send(p); err != nil {
+			log.WithFields(log.Fields{"Player": pl.Name, "err": err}).Debug("Failed to send lobby info")
+		}
 	}
 }
 
 func (l *Lobby) AddPlayer(pl *Player) error {
+	l.playersMu.Lock()
 	if l.MaxPlayers <= (int32)(len(l.Players)) {
+		l.playersMu.Unlock()
 		return fmt.Errorf("Lobby full")
 	}
-	log.WithFields(log.Fields{"Player": pl.Name, "Lobby": l.Name}).Trace("Added player to lobby")
 	l.Players = append(l.Players, pl)
+	l.playersMu.Unlock()
+	log.WithFields(log.Fields{"Player": pl.Name, "Lobby": l.Name}).Trace("Added player to lobby")
 	l.BroadcastInfo()
 	return nil
 }
 
 func (l *Lobby) RemovePlayer(pl *Player) {
 	log.WithFields(log.Fields{"Player": pl.Name, "Lobby": l.Name}).Trace("Removed player from lobby")
+	l.playersMu.Lock()
 	if len(l.Players) == 1 {
+		l.playersMu.Unlock()
 		log.WithFields(log.Fields{"Player": pl.Name, "Lobby": l.Name}).Trace("Player was last in lobby")
 		l.MessageChannel <- LobbyShutdown
 		return
@@ -42,19 +62,42 @@ func (l *Lobby) RemovePlayer(pl *Player) {
 	l.Players = slices.DeleteFunc(l.Players, func(n *Player) bool {
 		return n == pl
 	})
+	l.playersMu.Unlock()
 	pl.Lobby = nil
+	if l.Owner == pl {
+		l.migrateOwner(pl)
+		pl.IsHost = false
+	}
 
 	l.BroadcastInfo()
 }
 
 func (server *GameServer) InitializeLobby(l *Lobby) {
-	log.WithFields(log.Fields{"Name": l.Name, "Owner": l.Owner.Name, "Max_players": l.MaxPlayers, "Password": l.Password}).Trace("Initializing lobby")
+	log.WithFields(log.Fields{"Name": l.Name, "Owner": l.Owner.Name, "Max_players": l.MaxPlayers, "Password": l.Password, "Code": l.JoinCode}).Trace("Initializing lobby")
+	l.HostMigration = server.HostMigration
+	l.Created = time.Now()
+	l.setOwner(l.Owner)
 	l.LogicChannel = make(chan Packet, 100)
 	l.MessageChannel = make(chan LobbyMessage, 30)
 	server.Lobbies = append(server.Lobbies, l)
 	go l.lobbyLogicLoop(server)
 }
 
+// lobbyListChanged tells clients browsing the lobby list to fetch it again
+func (s *GameServer) lobbyListChanged() {
+	listChanged := Packet{}
+	listChanged.Header = messages.Data
+	listChanged.Flag = messages.Response.LobbyListChanged
+
+	for _, cl := range s.clientList() {
+		if cl.ConnectedPlayer.Lobby == nil {
+			if err := cl.Send(&listChanged); err != nil {
+				log.WithFields(log.Fields{"IP": cl.IP, "err": err}).Debug("Failed to send lobby list change")
+			}
+		}
+	}
+}
+
 func (lobby *Lobby) lobbyLogicLoop(server *GameServer) {
 	ticker := time.NewTicker(lobby.TickRate)
 	for {
@@ -76,11 +119,7 @@ func (lobby *Lobby) lobbyLogicLoop(server *GameServer) {
 		case msg := <-lobby.LogicChannel:
 			switch msg.Flag {
 			case messages.Post.PlayerTransformData:
-				var transformPacStruct struct {
-					ID         int32
-					Transforms Transforms
-					Inputs     Inputs
-				}
+				var transformPacStruct PlayerTransformPacket
 				if err := msg.ReadPayload(&transformPacStruct); err != nil {
 					log.WithFields(log.Fields{"Player": msg.Client.ConnectedPlayer.Name, "err": err}).Debug("Invalid player transform packet")
 					msg.Client.RespondError("INVALID_PACKET", false)
@@ -95,7 +134,8 @@ func (lobby *Lobby) lobbyLogicLoop(server *GameServer) {
 
 func (lobby *Lobby) lobbyTick(server *GameServer) {
 	var updatedPlayersPos []PlayerData
-	for _, pl := range lobby.Players {
+	players := lobby.playerList()
+	for _, pl := range players {
 		if pl.Transforms != pl.FutureTransforms {
 			// TODO : some calculations
 			pl.Transforms = pl.FutureTransforms
@@ -104,19 +144,20 @@ func (lobby *Lobby) lobbyTick(server *GameServer) {
 		}
 	}
 
-	if len(updatedPlayersPos) != 0 && len(lobby.Players) > 1 {
-		var playersPosUpdatePacket struct {
-			Players []PlayerData
-		}
+	if len(updatedPlayersPos) != 0 && len(players) > 1 {
+		var playersPosUpdatePacket PlayerTransformsPacket
 		playersPosUpdatePacket.Players = updatedPlayersPos
 
-		pac := Packet{}
-		pac.Header = messages.Data
-		pac.Flag = messages.Response.PlayerTransforms
-		pac.AddToPayload(&playersPosUpdatePacket)
-		//log.Debug(err)
-		for _, pl := range lobby.Players {
-			pac.Send(*pl.NetworkClient.Conn)
+		pac := newVersionedPacket(messages.Data, messages.Response.PlayerTransforms, &playersPosUpdatePacket)
+		for _, pl := range players {
+			p, err := pac.forClient(pl.NetworkClient)
+			if err != nil {
+				log.WithField("Error", err.Error()).Error("Adding player transforms to packet failed")
+				return
+			}
+			if err := server.SendChannel(pl.NetworkClient, p, messages.Channel.UnreliableSequenced); err != nil {
+				log.WithFields(log.Fields{"Player": pl.Name, "err": err}).Debug("Failed to send player transforms")
+			}
 		}
 	}
 }
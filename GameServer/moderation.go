@@ -0,0 +1,72 @@
+package GameServer
+
+import (
+	"fmt"
+
+	"MonophobiaServer/messages"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// KickPlayerPacket is the payload of Post.KickPlayer and Post.BanPlayer, only the lobby owner may send them
+type KickPlayerPacket struct {
+	PlayerID int32
+	Reason   string `mono:"max=128"`
+}
+
+// KickedPacket tells a player it was removed from a lobby by the owner
+type KickedPacket struct {
+	LobbyID int32
+	Reason  string `mono:"max=128"`
+	// Banned is set if the player can't join this lobby again
+	Banned bool
+}
+
+// LobbyBan keeps a player out of one lobby until it closes
+type LobbyBan struct {
+	PlayerID int32
+	SteamID  string
+}
+
+// isBanned matches by player ID, or by SteamID so a reconnect under a new ID doesn't get around it
+func (l *Lobby) isBanned(pl *Player) bool {
+	for _, ban := range l.Bans {
+		if ban.PlayerID == pl.ID || (ban.SteamID != "" && ban.SteamID == pl.SteamID) {
+			return true
+		}
+	}
+	return false
+}
+
+// kick removes a player on the owner's behalf and tells it why, the returned error is the reason sent back to the owner
+func (l *Lobby) kick(from *Player, req *KickPlayerPacket, ban bool) error {
+	if l.Owner != from {
+		return fmt.Errorf("NOT_LOBBY_OWNER")
+	}
+	var target *Player
+	for _, pl := range l.playerList() {
+		if pl.ID == req.PlayerID {
+			target = pl
+			break
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("PLAYER_NOT_FOUND")
+	}
+	if target == from {
+		return fmt.Errorf("CANNOT_KICK_SELF")
+	}
+	if ban {
+		l.Bans = append(l.Bans, LobbyBan{PlayerID: target.ID, SteamID: target.SteamID})
+	}
+	log.WithFields(log.Fields{"Lobby": l.Name, "Player": target.Name, "Reason": req.Reason, "Banned": ban}).Debug("Owner removed player from lobby")
+
+	kicked := target.NetworkClient.NewPacket(messages.Data, messages.Response.Kicked)
+	if err := kicked.AddToPayload(&KickedPacket{LobbyID: l.ID, Reason: req.Reason, Banned: ban}); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to add kick notice to packet")
+	} else if err := target.NetworkClient.Send(&kicked); err != nil {
+		log.WithFields(log.Fields{"Player": target.Name, "err": err}).Debug("Failed to send kick notice")
+	}
+	l.RemovePlayer(target)
+	return nil
+}
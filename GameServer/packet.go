@@ -2,13 +2,16 @@ package GameServer
 
 import (
 	"MonophobiaServer/messages"
+	"MonophobiaServer/monotag"
 	"bytes"
+	"cmp"
 	"encoding/binary"
 	"fmt"
 	"io"
-	"math"
 	"net"
 	"reflect"
+	"slices"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -18,19 +21,48 @@ var (
 )
 
 func (packet *Packet) assembleMessage() ([]byte, error) {
+	return packet.assembleFrame(0, nil, true)
+}
+
+// assembleFrame compresses payloads of at least compressThreshold bytes, 0 disables compression.
+// The payload goes uncompressed if compressing doesn't make it smaller.
+// With a sealer the (compressed) payload is encrypted, nil sends it in plaintext.
+// fullLength counts the header in the length field, clients older than FrameLengthVersion expect only the payload there.
+func (packet *Packet) assembleFrame(compressThreshold int, sealer *cipherState, fullLength bool) ([]byte, error) {
+	payload := packet.Payload
+	var flags uint32
+	if compressThreshold > 0 && len(payload) >= compressThreshold {
+		compressed, err := compressPayload(payload)
+		if err != nil {
+			return nil, err
+		}
+		if len(compressed) < len(payload) {
+			payload = compressed
+			flags |= FrameCompressed
+		}
+	}
+	if sealer != nil {
+		payload = sealer.seal(frameAAD(packet.Header, packet.Flag), payload)
+		flags |= FrameEncrypted
+	}
 	var result []byte //make([]byte,7+len(packet.Payload))
 	result, _ = binary.Append(result, binary.BigEndian, (int16)(packet.Header))
 	result, _ = binary.Append(result, binary.BigEndian, (byte)(packet.Flag))
-	result, _ = binary.Append(result, binary.LittleEndian, int32(len(packet.Payload)))
-	result = append(result, packet.Payload...)
+	length := len(payload)
+	if fullLength {
+		// same as FrameReader and DigestData read it
+		length += HeaderSize
+	}
+	result, _ = binary.Append(result, binary.LittleEndian, uint32(length)|flags)
+	result = append(result, payload...)
 	return result, nil
 	//TODO: Check for invalid packet data
 
 }
 
 func (packet *Packet) DigestData(data *[]byte) error {
-	if len(*data) < 4 {
-		log.Debug("Tried digesting data with less than 4 data length!")
+	if len(*data) < HeaderSize {
+		log.Debug("Tried digesting data shorter than the packet header!")
 		return fmt.Errorf("Packet too short")
 	}
 	buf := bytes.NewReader(*data)
@@ -43,16 +75,21 @@ func (packet *Packet) DigestData(data *[]byte) error {
 
 	//packet.FullMsgLen = binary.LittleEndian.Uint32((*data)[3:7])
 
-	if err := binary.Read(buf, binary.LittleEndian, &packet.FullMsgLen); err != nil {
+	var lengthField uint32
+	if err := binary.Read(buf, binary.LittleEndian, &lengthField); err != nil {
 		return fmt.Errorf("failed reading packet message length %w", err)
 	}
+	packet.FullMsgLen, packet.frameFlags = splitLength(lengthField)
 
+	if packet.FullMsgLen < HeaderSize {
+		return fmt.Errorf("data corrupted: Message len too short : %d", packet.FullMsgLen)
+	}
 	if packet.FullMsgLen > (int32)(len(*data)) {
 		return fmt.Errorf("data corrupted: Message len too long : %d should be %d", packet.FullMsgLen, (int32)(len(*data)))
 	}
 	packet.Payload = (*data)[7:packet.FullMsgLen]
 	packet.payloadPointer = 0
-	return nil
+	return packet.inflate(DefaultMaxFrameSize)
 }
 
 func (packet *Packet) AddString(value string) {
@@ -61,7 +98,7 @@ func (packet *Packet) AddString(value string) {
 }
 
 func (packet *Packet) AddFloat(value float32) {
-	packet.Payload = append(packet.Payload, byte(math.Float32bits(value)))
+	packet.Payload, _ = binary.Append(packet.Payload, binary.LittleEndian, value)
 }
 func (packet *Packet) AddBool(value bool) {
 	if value {
@@ -74,22 +111,27 @@ func (packet *Packet) AddInt(value int32) {
 	packet.Payload, _ = binary.Append(packet.Payload, binary.LittleEndian, value)
 }
 
-func (packet *Packet) Send(conn net.Conn) {
+// Send hands the packet to conn, for clients Client.Send also deals with clients that can't keep up
+func (packet *Packet) Send(conn Connection) error {
+	return conn.Send(packet)
+}
+
+// SendUDPTo sends the packet as a single datagram, see GameServer.SendUDP for packets that may not fit
+func (packet *Packet) SendUDPTo(conn *net.UDPConn, addr *net.UDPAddr) error {
 	payload, err := packet.assembleMessage()
 	if err != nil {
-		log.WithField("error", err.Error()).Error("Failed to assemble packet")
-		return
+		return fmt.Errorf("failed to assemble packet : %w", err)
 	}
-	conn.Write(payload)
+	_, err = conn.WriteToUDP(payload, addr)
+	return err
 }
 
-func (packet *Packet) SendUDP(conn net.UDPConn) {
-	payload, err := packet.assembleMessage()
-	if err != nil {
-		log.WithField("error", err.Error()).Error("Failed to assemble packet")
-		return
+// payloadVersion is the protocol version a payload is written/read for
+func (packet *Packet) payloadVersion() int32 {
+	if packet.Version == 0 {
+		return ProtocolVersion
 	}
-	conn.Write(payload)
+	return packet.Version
 }
 
 func (packet *Packet) AddToPayload(data interface{}) error {
@@ -97,179 +139,363 @@ func (packet *Packet) AddToPayload(data interface{}) error {
 		return fmt.Errorf("data must be a pointer to a struct, got %T", data)
 	}
 	buffer := bytes.NewBuffer(packet.Payload)
-	if err := serializeData(buffer, data); err != nil {
+	if m, ok := data.(MonoMarshaler); ok {
+		if err := m.MarshalMono(buffer, packet.payloadVersion()); err != nil {
+			return fmt.Errorf("failed to serialize data : %w", err)
+		}
+		packet.Payload = buffer.Bytes()
+		return nil
+	}
+	if err := serializeData(buffer, data, packet.payloadVersion()); err != nil {
 		return fmt.Errorf("failed to serialize data : %w", err)
 	}
 
 	packet.Payload = buffer.Bytes()
 	return nil
 }
-func serializeData(buffer *bytes.Buffer, data interface{}) error {
-	//log.Trace("Serialization start")
+
+// versionedPacket is one payload going to many clients. It is encoded once per protocol version among them.
+type versionedPacket struct {
+	header messages.Header
+	flag   messages.Flag
+	data   interface{}
+	built  map[int32]*Packet
+}
+
+func newVersionedPacket(header messages.Header, flag messages.Flag, data interface{}) *versionedPacket {
+	return &versionedPacket{header: header, flag: flag, data: data, built: make(map[int32]*Packet)}
+}
+
+func (v *versionedPacket) forClient(c *Client) (*Packet, error) {
+	if pac, ok := v.built[c.ProtocolVersion]; ok {
+		return pac, nil
+	}
+	pac := c.NewPacket(v.header, v.flag)
+	if err := pac.AddToPayload(v.data); err != nil {
+		return nil, err
+	}
+	v.built[c.ProtocolVersion] = &pac
+	return &pac, nil
+}
+
+type wireField struct {
+	index int
+	name  string
+	tag   monotag.Tag
+}
+
+var wireFieldsCache sync.Map // reflect.Type -> []wireField
+
+// wireFields lists the fields of a struct type that go on the wire, together with their mono tags.
+// Unexported and `mono:"-"` fields are left out.
+func wireFields(t reflect.Type) ([]wireField, error) {
+	if cached, ok := wireFieldsCache.Load(t); ok {
+		return cached.([]wireField), nil
+	}
+	var fields []wireField
+	optional := false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag, err := monotag.Parse(f.Tag.Get(monotag.Key))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		if tag.Skip {
+			continue
+		}
+		if tag.Wire != "" {
+			if err := checkWireType(f.Type, tag.Wire); err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.Name, err)
+			}
+		}
+		if optional && !tag.Optional {
+			return nil, fmt.Errorf("field %s: follows an optional field but isn't optional", f.Name)
+		}
+		optional = tag.Optional
+		fields = append(fields, wireField{i, f.Name, tag})
+	}
+	wireFieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+// checkWireType makes sure every value of the wire type fits back into the (innermost) integer type of the field
+func checkWireType(t reflect.Type, wire string) error {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	native, ok := monotag.IntTypes[t.Kind().String()]
+	if !ok {
+		return fmt.Errorf("wire=%s on non integer type %v", wire, t)
+	}
+	if !native.Contains(monotag.IntTypes[wire]) {
+		return fmt.Errorf("wire=%s does not fit into %v", wire, t)
+	}
+	return nil
+}
+
+func serializeData(buffer *bytes.Buffer, data interface{}, version int32) error {
 	if reflect.TypeOf(data).Kind() != reflect.Ptr {
 		return fmt.Errorf("data must be a pointer to a struct, got %T", data)
 	}
 	v := reflect.ValueOf(data).Elem()
-	//log.WithFields(log.Fields{"Struct len": v.NumField()}).Trace("Serializing data")
-	for i := 0; i < v.NumField(); i += 1 {
-		field := v.Field(i)
-		//log.WithFields(log.Fields{"Type": field.Kind()}).Trace("Serializing data")
-		switch field.Kind() {
-		case reflect.String:
-			var strlen int32
-			strlen = (int32)(len(field.String()))
-			if err := binary.Write(buffer, Endianess, strlen); err != nil {
-				return fmt.Errorf("failed to write string length for field %d: %w", i, err)
-			}
-			if err := binary.Write(buffer, Endianess, ([]byte)(field.String())); err != nil {
-				return fmt.Errorf("failed to write string data for field %d: %w", i, err)
-			}
-		case reflect.Int32:
-			if err := binary.Write(buffer, Endianess, (int32)(field.Int())); err != nil {
-				return fmt.Errorf("failed to write data for field %d: %w", i, err)
-			}
-		case reflect.Float32:
-			if err := binary.Write(buffer, Endianess, (float32)(field.Float())); err != nil {
-				return fmt.Errorf("failed to write data for field %d: %w", i, err)
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("data must be a pointer to a struct, got %T", data)
+	}
+	return encodeValue(buffer, v, monotag.Tag{}, version)
+}
+
+func encodeValue(buffer *bytes.Buffer, v reflect.Value, tag monotag.Tag, version int32) error {
+	// max only limits the outermost container, wire is passed down to the integers inside
+	elemTag := monotag.Tag{Wire: tag.Wire}
+	switch v.Kind() {
+	case reflect.Bool:
+		writeBool(buffer, v.Bool())
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if tag.Wire == "" {
+			writeUintN(buffer, int(v.Type().Size()), uint64(v.Int()))
+			return nil
+		}
+		wire := monotag.IntTypes[tag.Wire]
+		if !wire.FitsInt(v.Int()) {
+			return fmt.Errorf("value %d does not fit into %s", v.Int(), tag.Wire)
+		}
+		writeUintN(buffer, wire.Bits/8, uint64(v.Int()))
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if tag.Wire == "" {
+			writeUintN(buffer, int(v.Type().Size()), v.Uint())
+			return nil
+		}
+		wire := monotag.IntTypes[tag.Wire]
+		if !wire.FitsUint(v.Uint()) {
+			return fmt.Errorf("value %d does not fit into %s", v.Uint(), tag.Wire)
+		}
+		writeUintN(buffer, wire.Bits/8, v.Uint())
+	case reflect.Float32:
+		writeFloat32(buffer, float32(v.Float()))
+	case reflect.Float64:
+		writeFloat64(buffer, v.Float())
+	case reflect.String:
+		if tag.Max > 0 && v.Len() > tag.Max {
+			return fmt.Errorf("string length %d over maximum of %d", v.Len(), tag.Max)
+		}
+		writeString(buffer, v.String())
+	case reflect.Struct:
+		fields, err := wireFields(v.Type())
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if f.tag.Since > version {
+				continue
 			}
-		case reflect.Bool:
-			if err := binary.Write(buffer, Endianess, field.Bool()); err != nil {
-				return fmt.Errorf("failed to write data for field %d: %w", i, err)
+			if err := encodeValue(buffer, v.Field(f.index), f.tag, version); err != nil {
+				return fmt.Errorf("field %s: %w", f.name, err)
 			}
-		case reflect.Struct:
-			if err := serializeData(buffer, field.Addr().Interface()); err != nil {
-				return fmt.Errorf("failed to serialize struct for field %d: %w", i, err)
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			writeBool(buffer, false)
+			return nil
+		}
+		writeBool(buffer, true)
+		return encodeValue(buffer, v.Elem(), tag, version)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i += 1 {
+			if err := encodeValue(buffer, v.Index(i), elemTag, version); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
 			}
-		case reflect.Pointer:
-			if field.Elem().Kind() == reflect.Struct {
-				if err := serializeData(buffer, field.Elem().Interface()); err != nil {
-					return fmt.Errorf("failed to serialize struct pointer for field %d: %w", i, err)
-				}
-			} else {
-				return fmt.Errorf("unsupported pointer type: %v", field.Type())
+		}
+	case reflect.Slice:
+		if tag.Max > 0 && v.Len() > tag.Max {
+			return fmt.Errorf("slice length %d over maximum of %d", v.Len(), tag.Max)
+		}
+		writeInt32(buffer, int32(v.Len()))
+		for i := 0; i < v.Len(); i += 1 {
+			if err := encodeValue(buffer, v.Index(i), elemTag, version); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
 			}
-		case reflect.Slice:
-			var slicelen int32
-			slicelen = (int32)(field.Len())
-			//log.WithFields(log.Fields{"slicelen": slicelen}).Trace("slice len")
-
-			if err := binary.Write(buffer, Endianess, slicelen); err != nil {
-				return fmt.Errorf("failed to write slice length for field %d: %w", i, err)
+		}
+	case reflect.Map:
+		if tag.Max > 0 && v.Len() > tag.Max {
+			return fmt.Errorf("map length %d over maximum of %d", v.Len(), tag.Max)
+		}
+		keys := v.MapKeys()
+		if err := sortMapKeys(keys, v.Type().Key()); err != nil {
+			return err
+		}
+		writeInt32(buffer, int32(len(keys)))
+		for _, key := range keys {
+			if err := encodeValue(buffer, key, monotag.Tag{}, version); err != nil {
+				return fmt.Errorf("map key %v: %w", key, err)
 			}
-			for k := 0; k < int(slicelen); k += 1 {
-				//log.WithFields(log.Fields{"Type": field.Type().Elem().Kind()}).Trace("Serializing slice element")
-
-				switch field.Type().Elem().Kind() {
-				case reflect.Pointer:
-					if field.Index(k).Elem().Kind() == reflect.Struct {
-						if err := serializeData(buffer, field.Index(k).Elem().Addr().Interface()); err != nil {
-							return fmt.Errorf("failed to serialize struct pointer for slize element %d: %w", i, err)
-						}
-					} else {
-						return fmt.Errorf("unsupported pointer type: %v", field.Type())
-					}
-				case reflect.Struct:
-					//log.WithFields(log.Fields{"Type": field.Type().Elem().Kind(), "xd": field.Index(k)}).Trace("dupsko serialization")
-					if err := serializeData(buffer, field.Index(k).Addr().Interface()); err != nil {
-						return fmt.Errorf("failed to serialize slize element %d: %w", k, err)
-					}
-				case reflect.String:
-					var strlen int32
-					strlen = (int32)(len(field.Index(k).String()))
-					if err := binary.Write(buffer, Endianess, strlen); err != nil {
-						return fmt.Errorf("failed to write string length for slize element %d: %w", i, err)
-					}
-					if err := binary.Write(buffer, Endianess, ([]byte)(field.Index(k).String())); err != nil {
-						return fmt.Errorf("failed to write string data for slize element %d: %w", i, err)
-					}
-				case reflect.Int32:
-					if err := binary.Write(buffer, Endianess, (int32)(field.Index(k).Int())); err != nil {
-						return fmt.Errorf("failed to write data for slize element %d: %w", i, err)
-					}
-				case reflect.Float32:
-					if err := binary.Write(buffer, Endianess, (float32)(field.Index(k).Float())); err != nil {
-						return fmt.Errorf("failed to write data for slize element %d: %w", i, err)
-					}
-				case reflect.Bool:
-					if err := binary.Write(buffer, Endianess, field.Index(k).Bool()); err != nil {
-						return fmt.Errorf("failed to write data for slize element %d: %w", i, err)
-					}
-				default:
-					return fmt.Errorf("unsupported slice field type: %v", field.Index(k).Kind())
-				}
+			if err := encodeValue(buffer, v.MapIndex(key), elemTag, version); err != nil {
+				return fmt.Errorf("map value %v: %w", key, err)
 			}
-		default:
-			return fmt.Errorf("unsupported field type: %v", field.Kind())
 		}
+	default:
+		// int and uint are left out on purpose, their size depends on the platform
+		return fmt.Errorf("unsupported field type: %v", v.Type())
+	}
+	return nil
+}
+
+// sortMapKeys puts map keys in their natural order so the same map always encodes to the same bytes
+func sortMapKeys(keys []reflect.Value, keyType reflect.Type) error {
+	switch keyType.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return cmp.Compare(a.Int(), b.Int()) })
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return cmp.Compare(a.Uint(), b.Uint()) })
+	case reflect.Float32, reflect.Float64:
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return cmp.Compare(a.Float(), b.Float()) })
+	case reflect.String:
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return cmp.Compare(a.String(), b.String()) })
+	default:
+		return fmt.Errorf("unsupported map key type: %v", keyType)
 	}
 	return nil
 }
+
 func (packet *Packet) ReadPayload(out interface{}) error {
 	if reflect.TypeOf(out).Kind() != reflect.Ptr {
 		return fmt.Errorf("out must be a pointer to a struct, got %T", out)
 	}
 	r := bytes.NewReader(packet.Payload)
-	return deserializeData(r, out)
+	if u, ok := out.(MonoUnmarshaler); ok {
+		return u.UnmarshalMono(r, packet.payloadVersion())
+	}
+	return deserializeData(r, out, packet.payloadVersion())
 }
 
-func deserializeData(reader *bytes.Reader, out interface{}) error {
+func deserializeData(reader *bytes.Reader, out interface{}, version int32) error {
+	if reflect.TypeOf(out).Kind() != reflect.Ptr {
+		return fmt.Errorf("out must be a pointer to a struct, got %T", out)
+	}
 	v := reflect.ValueOf(out).Elem()
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		switch field.Kind() {
-		case reflect.String:
-			// Read int32 length for the string
-			var strlen int32
-			if err := binary.Read(reader, Endianess, &strlen); err != nil {
-				return fmt.Errorf("failed to read string length for field %d: %w", i, err)
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("out must be a pointer to a struct, got %T", out)
+	}
+	return decodeValue(reader, v, monotag.Tag{}, version)
+}
+
+func decodeValue(reader *bytes.Reader, v reflect.Value, tag monotag.Tag, version int32) error {
+	elemTag := monotag.Tag{Wire: tag.Wire}
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := readBool(reader)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		bits := int(v.Type().Size()) * 8
+		signed := v.CanInt()
+		if tag.Wire != "" {
+			bits = monotag.IntTypes[tag.Wire].Bits
+			signed = monotag.IntTypes[tag.Wire].Signed
+		}
+		n, err := readUintN(reader, bits/8)
+		if err != nil {
+			return err
+		}
+		if signed {
+			// sign extend from the wire width
+			shift := 64 - bits
+			n = uint64(int64(n<<shift) >> shift)
+		}
+		if v.CanInt() {
+			v.SetInt(int64(n))
+		} else {
+			v.SetUint(n)
+		}
+	case reflect.Float32:
+		f, err := readFloat32(reader)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(float64(f))
+	case reflect.Float64:
+		f, err := readFloat64(reader)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		str, err := readStringMax(reader, tag.Max)
+		if err != nil {
+			return err
+		}
+		v.SetString(str)
+	case reflect.Struct:
+		fields, err := wireFields(v.Type())
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if f.tag.Since > version {
+				continue
+			}
+			if f.tag.Optional && reader.Len() == 0 {
+				break
 			}
-			// Read the actual string bytes
-			buf := make([]byte, strlen)
-			if _, err := io.ReadFull(reader, buf); err != nil {
-				return fmt.Errorf("failed to read string data for field %d: %w", i, err)
+			if err := decodeValue(reader, v.Field(f.index), f.tag, version); err != nil {
+				return fmt.Errorf("field %s: %w", f.name, err)
+			}
+		}
+	case reflect.Pointer:
+		present, err := readPresence(reader)
+		if err != nil {
+			return err
+		}
+		if !present {
+			v.SetZero()
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := decodeValue(reader, elem.Elem(), tag, version); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := decodeValue(reader, v.Index(i), elemTag, version); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
 			}
-			field.SetString(string(buf))
-		case reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64, reflect.Bool:
-			// Directly read these supported types
-			if err := binary.Read(reader, Endianess, field.Addr().Interface()); err != nil {
-				return fmt.Errorf("failed to read field %d: %w", i, err)
+		}
+	case reflect.Slice:
+		n, err := readLengthMax(reader, tag.Max)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+		for i := 0; i < n; i++ {
+			if err := decodeValue(reader, v.Index(i), elemTag, version); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
 			}
-		case reflect.Struct:
-			deserializeData(reader, field.Addr().Interface())
-		case reflect.Slice:
-			elemType := field.Type().Elem()
-			var slicelen int32
-			if err := binary.Read(reader, Endianess, &slicelen); err != nil {
-				return fmt.Errorf("failed to read slice length for field %d: %w", i, err)
+		}
+	case reflect.Map:
+		n, err := readLengthMax(reader, tag.Max)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.MakeMapWithSize(v.Type(), n))
+		for i := 0; i < n; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := decodeValue(reader, key, monotag.Tag{}, version); err != nil {
+				return fmt.Errorf("map key %d: %w", i, err)
 			}
-			field.Set(reflect.MakeSlice(field.Type(), int(slicelen), int(slicelen)))
-			for j := 0; j < int(slicelen); j += 1 {
-				elem := field.Index(j)
-				switch elemType.Kind() {
-				case reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64, reflect.Bool:
-					// Directly read these supported types
-					if err := binary.Read(reader, Endianess, elem.Addr().Interface()); err != nil {
-						return fmt.Errorf("failed to read field %d: %w", j, err)
-					}
-				case reflect.String:
-					// Read int32 length for the string
-					var strlen int32
-					if err := binary.Read(reader, Endianess, &strlen); err != nil {
-						return fmt.Errorf("failed to read string length for field %d: %w", j, err)
-					}
-					// Read the actual string bytes
-					buf := make([]byte, strlen)
-					if _, err := io.ReadFull(reader, buf); err != nil {
-						return fmt.Errorf("failed to read string data for field %d: %w", j, err)
-					}
-					elem.SetString(string(buf))
-				case reflect.Struct:
-					deserializeData(reader, elem)
-				}
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(reader, value, elemTag, version); err != nil {
+				return fmt.Errorf("map value %v: %w", key, err)
 			}
-		default:
-			return fmt.Errorf("unsupported field type: %v", field.Kind())
+			v.SetMapIndex(key, value)
 		}
+	default:
+		return fmt.Errorf("unsupported field type: %v", v.Type())
 	}
 	return nil
 }
@@ -12,23 +12,26 @@ import (
 // Here we only parse and execute actions that are non lobby dependant (these we pass down to the lobby)
 func (s *GameServer) ParsePacket(packet *Packet) { //, client *Client) {
 	client := packet.Client
+	if !s.allowPacket(client, packet) {
+		return
+	}
+	packet.Version = client.ProtocolVersion
 	switch packet.Header {
 	case messages.Data:
 		switch packet.Flag {
 		case messages.Post.CreateLobby:
-			var createPacketStruct struct {
-				Name                string
-				MaxPlayers          int32
-				IsPasswordProtected bool
-				Password            string
+			if client.ConnectedPlayer.Lobby != nil {
+				client.RespondError("ALREADY_IN_LOBBY", false)
+				return
 			}
+			var createPacketStruct CreateLobbyPacket
 			err := packet.ReadPayload(&createPacketStruct)
 			if err != nil {
 				log.Debug(err.Error())
 				client.RespondError("INVALID_PACKET", false)
 				return
 			}
-			if createPacketStruct.MaxPlayers < 3 {
+			if createPacketStruct.MaxPlayers < MinLobbyPlayers {
 				client.RespondError("MAX_PLAYERS_TOO_SMALL", false)
 				return
 			}
@@ -40,7 +43,8 @@ func (s *GameServer) ParsePacket(packet *Packet) { //, client *Client) {
 			newLobby.Password = createPacketStruct.Password
 			newLobby.Owner = client.ConnectedPlayer
 			newLobby.Map = Maps.Lobby
-			newLobby.ID = client.ConnectedPlayer.ID
+			newLobby.ID = s.newLobbyID()
+			newLobby.JoinCode = s.newJoinCode()
 			client.ConnectedPlayer.Lobby = newLobby
 			newLobby.TickRate = time.Millisecond * 20
 			s.InitializeLobby(newLobby)
@@ -49,57 +53,92 @@ func (s *GameServer) ParsePacket(packet *Packet) { //, client *Client) {
 				log.Debug(err.Error())
 			}
 
-			listChanged := Packet{}
-			listChanged.Header = messages.Data
-			listChanged.Flag = messages.Response.LobbyListChanged
-
-			for _, cl := range s.Clients {
-				if cl.ConnectedPlayer.Lobby == nil {
-					listChanged.Send(*cl.Conn)
-				}
-			}
+			s.lobbyListChanged()
 		case messages.Request.LobbyList:
-			resp := Packet{}
-			resp.Header = messages.Data
-			resp.Flag = messages.Response.LobbyList
-			resp.AddInt((int32)(len(s.Lobbies)))
-			for _, lb := range s.Lobbies {
-				resp.AddInt(lb.ID)
-				resp.AddString(lb.Name)
-				resp.AddBool(lb.PasswordProtected)
-				resp.AddInt((int32)(len(lb.Players)))
-				resp.AddInt(lb.MaxPlayers)
-			}
-			resp.Send(*client.Conn)
+			var req LobbyListRequestPacket
+			if err := packet.ReadPayload(&req); err != nil {
+				client.RespondError("INVALID_PACKET", false)
+				return
+			}
+			resp := client.NewPacket(messages.Data, messages.Response.LobbyList)
+			if err := resp.AddToPayload(s.lobbyList(client, &req)); err != nil {
+				log.WithField("error", err.Error()).Error("Failed to add lobby list to packet")
+				return
+			}
+			if err := client.Send(&resp); err != nil {
+				log.WithFields(log.Fields{"IP": client.IP, "err": err}).Debug("Failed to send lobby list")
+			}
 		case messages.Post.JoinLobby:
 			if client.ConnectedPlayer.Lobby != nil {
 				client.RespondError("ALREADY_IN_LOBBY", false)
 				return
 			}
-			var joinPacket struct {
-				LobbyID  int32
-				Password string
+			var joinPacket JoinLobbyPacket
+			if err := packet.ReadPayload(&joinPacket); err != nil {
+				client.RespondError("INVALID_PACKET", false)
+				return
+			}
+			s.joinLobby(client, s.lobbyByID(joinPacket.LobbyID), joinPacket.Password)
+		case messages.Post.JoinLobbyByCode:
+			if client.ConnectedPlayer.Lobby != nil {
+				client.RespondError("ALREADY_IN_LOBBY", false)
+				return
 			}
+			var joinPacket JoinLobbyByCodePacket
 			if err := packet.ReadPayload(&joinPacket); err != nil {
 				client.RespondError("INVALID_PACKET", false)
 				return
 			}
-			for _, lb := range s.Lobbies { // O(n) shouldn't be an issue, right?
-				if lb.ID == joinPacket.LobbyID {
-					if len(lb.Players) >= int(lb.MaxPlayers) {
-						client.RespondError("LOBBY_FULL", false)
-						return
-					}
-					if lb.PasswordProtected && lb.Password != joinPacket.Password {
-						client.RespondError("INVALID_PASSWORD", false)
-						return
-					}
-					packet.Client.ConnectedPlayer.Lobby = lb
-					lb.AddPlayer(packet.Client.ConnectedPlayer)
-					return
-				}
-			}
-			client.RespondError("LOBBY_NOT_FOUND", false)
+			s.joinLobby(client, s.lobbyByCode(joinPacket.Code), joinPacket.Password)
+		case messages.Post.TransferOwnership:
+			var transfer TransferOwnershipPacket
+			if err := packet.ReadPayload(&transfer); err != nil {
+				client.RespondError("INVALID_PACKET", false)
+				return
+			}
+			if client.ConnectedPlayer.Lobby == nil {
+				client.RespondError("NOT_IN_LOBBY", false)
+				return
+			}
+			if err := client.ConnectedPlayer.Lobby.transferOwnership(client.ConnectedPlayer, transfer.PlayerID); err != nil {
+				client.RespondError(err.Error(), false)
+			}
+		case messages.Post.UpdateLobbyInfo:
+			var update UpdateLobbyInfoPacket
+			if err := packet.ReadPayload(&update); err != nil {
+				client.RespondError("INVALID_PACKET", false)
+				return
+			}
+			if client.ConnectedPlayer.Lobby == nil {
+				client.RespondError("NOT_IN_LOBBY", false)
+				return
+			}
+			if err := client.ConnectedPlayer.Lobby.updateSettings(client.ConnectedPlayer, &update); err != nil {
+				client.RespondError(err.Error(), false)
+				return
+			}
+			s.lobbyListChanged()
+		case messages.Post.KickPlayer, messages.Post.BanPlayer:
+			var kick KickPlayerPacket
+			if err := packet.ReadPayload(&kick); err != nil {
+				client.RespondError("INVALID_PACKET", false)
+				return
+			}
+			if client.ConnectedPlayer.Lobby == nil {
+				client.RespondError("NOT_IN_LOBBY", false)
+				return
+			}
+			if err := client.ConnectedPlayer.Lobby.kick(client.ConnectedPlayer, &kick, packet.Flag == messages.Post.BanPlayer); err != nil {
+				client.RespondError(err.Error(), false)
+				return
+			}
+			s.lobbyListChanged()
+		case messages.Response.FragmentReceived:
+			var ack FragmentAckPacket
+			if err := packet.ReadPayload(&ack); err != nil || client.UDPAddr == nil {
+				return
+			}
+			s.fragments.ack(client.UDPAddr, ack)
 		case messages.Post.PlayerTransformData, messages.Post.ItemPickup, messages.Post.ItemDrop, messages.Post.ItemIntInf:
 			//All this wierdness is because somehoow the payload of the packet was cleared when pulled out of the channel.
 			r := *packet
@@ -113,13 +152,53 @@ func (s *GameServer) ParsePacket(packet *Packet) { //, client *Client) {
 			client.RespondError("FLAG_NOT_RECOGNIZED", false)
 
 		}
+	case messages.Punch:
+		s.handlePunch(client, packet)
+	case messages.Disconnecting:
+		// a goodbye, so the seat isn't kept for a resume
+		s.markLeaving(client)
+		client.Conn.Close()
 	case messages.Echo:
-		resp := Packet{}
-		resp.Header = messages.Echo
-		resp.Flag = messages.None
-		resp.Send(*packet.Client.Conn)
+		if client.ProtocolVersion < HeartbeatVersion {
+			resp := client.NewPacket(messages.Echo, messages.None)
+			if err := client.Send(&resp); err != nil {
+				log.WithFields(log.Fields{"IP": client.IP, "err": err}).Debug("Failed to answer echo")
+			}
+			return
+		}
+		if packet.Flag == messages.Heartbeat.Pong {
+			var pong HeartbeatPacket
+			if err := packet.ReadPayload(&pong); err != nil {
+				return
+			}
+			client.heartbeat.pong(pong.Sequence, time.Now())
+			return
+		}
+		// the client pings, answer with its own payload
+		resp := client.NewPacket(messages.Echo, messages.Heartbeat.Pong)
+		resp.Payload = append(resp.Payload, packet.Payload...)
+		if err := client.Send(&resp); err != nil {
+			log.WithFields(log.Fields{"IP": client.IP, "err": err}).Debug("Failed to answer echo")
+		}
 	default:
 		log.WithFields(log.Fields{"Header": strconv.FormatInt((int64)(packet.Header), 16), "IP": client.IP}).Warn("Header not recognized")
 		client.RespondError("HEADER_NOT_RECOGNIZED", false)
 	}
 }
+
+// joinLobby puts the client into lb after the capacity, ban and password checks, lb is nil if no lobby matched
+func (s *GameServer) joinLobby(client *Client, lb *Lobby, password string) {
+	switch {
+	case lb == nil:
+		client.RespondError("LOBBY_NOT_FOUND", false)
+	case lb.playerCount() >= int(lb.MaxPlayers):
+		client.RespondError("LOBBY_FULL", false)
+	case lb.isBanned(client.ConnectedPlayer):
+		client.RespondError("BANNED_FROM_LOBBY", false)
+	case lb.PasswordProtected && lb.Password != password:
+		client.RespondError("INVALID_PASSWORD", false)
+	default:
+		client.ConnectedPlayer.Lobby = lb
+		lb.AddPlayer(client.ConnectedPlayer)
+	}
+}
@@ -0,0 +1,87 @@
+package GameServer
+
+//go:generate go run ../tools/monogen -output codec_gen.go PlayerData NetworkLobbyInfo WorldState ErrorPacket HelloPacket IDAssignPacket ImHerePacket BindChallengePacket BindResponsePacket CreateLobbyPacket JoinLobbyPacket PlayerTransformPacket PlayerTransformsPacket FragmentHeader FragmentAckPacket SequencedHeader AckPacket HeartbeatPacket PunchRequestPacket PunchIntroductionPacket PunchResultPacket RelayHeader TransferOwnershipPacket UpdateLobbyInfoPacket KickPlayerPacket KickedPacket JoinLobbyByCodePacket LobbyListCursor LobbyListRequestPacket LobbyListEntry LobbyListPacket
+
+// Payloads of packets that used to be declared inline in the handlers.
+// They are named so monogen can generate codecs for them.
+//
+// New fields go at the end of a payload with `mono:"since=N"` and ProtocolVersion bumped to N,
+// so clients that still speak N-1 keep getting the layout they expect. Run go generate after changing any of them.
+
+// ErrorPacket is the payload of Rejected and Disconnecting
+type ErrorPacket struct {
+	Message string
+	// Details carries machine readable context for some errors, e.g. min_version for INVALID_VERSION
+	Details map[string]string `mono:"optional"`
+}
+
+type HelloPacket struct {
+	// Name is a Steam name of up to 32 characters, max counts bytes and a character takes up to 4 of them
+	Name    string `mono:"max=128"`
+	SteamID string `mono:"max=32"`
+	Version string `mono:"max=32"`
+	// Capabilities is a messages.Capability bitset, clients that predate it just don't send it
+	Capabilities uint32 `mono:"optional"`
+	// PublicKey is the client's X25519 key when it asks for messages.Capabilities.Encryption
+	PublicKey []byte `mono:"optional,max=32"`
+	// ProtocolVersion is the newest payload layout the client speaks, clients that don't send it speak version 1.
+	// Hello is read before a version is agreed on, so it only ever grows optional fields, never since ones.
+	ProtocolVersion int32 `mono:"optional"`
+	// ResumeToken is the token of the last IDAssign if the client is reconnecting after its connection dropped
+	ResumeToken []byte `mono:"optional,max=32"`
+}
+
+type IDAssignPacket struct {
+	ID int32
+	// Capabilities are the ones enabled for this connection, from here on both sides may use them
+	Capabilities uint32 `mono:"optional"`
+	// PublicKey is the server's X25519 key if encryption was enabled, empty otherwise
+	PublicKey []byte `mono:"optional,max=32"`
+	// ProtocolVersion is the payload layout both sides use from here on
+	ProtocolVersion int32 `mono:"optional"`
+	// ResumeToken lets the client get its player back if the connection drops, empty if the server doesn't allow resuming
+	ResumeToken []byte `mono:"optional,max=32"`
+	// Resumed tells if the Hello's ResumeToken was accepted, if not this is a fresh player
+	Resumed bool `mono:"optional"`
+	// UDPSecret is the key for the MAC in BindResponsePacket, new for every connection
+	UDPSecret []byte `mono:"optional,max=32"`
+}
+
+type ImHerePacket struct {
+	ID int32
+}
+
+// BindChallengePacket is sent to the address an ImHere came from, only someone receiving there can answer it
+type BindChallengePacket struct {
+	Cookie []byte `mono:"max=64"`
+}
+
+type BindResponsePacket struct {
+	ID     int32
+	Cookie []byte `mono:"max=64"`
+	// MAC is HMAC-SHA256 keyed with IDAssign's UDPSecret over Cookie
+	MAC []byte `mono:"max=32"`
+}
+
+type CreateLobbyPacket struct {
+	// Name is up to 64 characters of up to 4 bytes each
+	Name                string `mono:"max=256"`
+	MaxPlayers          int32
+	IsPasswordProtected bool
+	Password            string `mono:"max=64"`
+}
+
+type JoinLobbyPacket struct {
+	LobbyID  int32
+	Password string `mono:"max=64"`
+}
+
+type PlayerTransformPacket struct {
+	ID         int32
+	Transforms Transforms
+	Inputs     Inputs
+}
+
+type PlayerTransformsPacket struct {
+	Players []PlayerData
+}
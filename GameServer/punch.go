@@ -0,0 +1,271 @@
+package GameServer
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+
+	"MonophobiaServer/messages"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// punchTimeout is how long both peers get to report a result once they started punching
+	punchTimeout = 5 * time.Second
+	// punchMargin is added to the time the slower peer needs to hear about the introduction
+	punchMargin = 100 * time.Millisecond
+	// relayHeaderSize is the encoded size of RelayHeader
+	relayHeaderSize = 4
+)
+
+// PunchRequestPacket asks for an introduction to another player of the lobby.
+// The private endpoint is the client's address in its own network, in case both peers sit behind the same NAT.
+type PunchRequestPacket struct {
+	PeerID      int32
+	PrivateIP   string `mono:"max=45"`
+	PrivatePort int32
+}
+
+// PunchIntroductionPacket is sent to both peers. They send "holepunch" datagrams at both endpoints of the other,
+// starting after Delay milliseconds so the attempts of both sides cross.
+type PunchIntroductionPacket struct {
+	PeerID      int32
+	Token       uint32
+	PublicIP    string `mono:"max=45"`
+	PublicPort  int32
+	PrivateIP   string `mono:"max=45"`
+	PrivatePort int32
+	Delay       int32
+}
+
+// PunchResultPacket is reported by both peers, the server then sends it back with the outcome for the pair.
+// If punching failed Relayed tells that the server forwards Relay packets between the two instead.
+type PunchResultPacket struct {
+	PeerID  int32
+	Token   uint32
+	Success bool
+	Relayed bool
+}
+
+// RelayHeader starts the payload of a Relay packet. Sent by a client it is the peer the data is for,
+// forwarded by the server it is the peer the data comes from. The data follows it as is.
+type RelayHeader struct {
+	PeerID int32
+}
+
+type peerPair struct {
+	low, high int32
+}
+
+func newPeerPair(a, b int32) peerPair {
+	if a > b {
+		a, b = b, a
+	}
+	return peerPair{a, b}
+}
+
+// introduction is one punching attempt between two players, kept afterwards if the server relays for them
+type introduction struct {
+	token    uint32
+	reported map[int32]bool
+	timer    *time.Timer
+	relaying bool
+}
+
+type introductions struct {
+	mu    sync.Mutex
+	pairs map[peerPair]*introduction
+}
+
+func (s *GameServer) samePeerLobby(a, b *Client) bool {
+	return a.ConnectedPlayer != nil && b.ConnectedPlayer != nil && a.ConnectedPlayer.Lobby != nil && a.ConnectedPlayer.Lobby == b.ConnectedPlayer.Lobby
+}
+
+func (s *GameServer) handlePunch(client *Client, packet *Packet) {
+	switch packet.Flag {
+	case messages.Introduction.Request:
+		var req PunchRequestPacket
+		if err := packet.ReadPayload(&req); err != nil {
+			client.RespondError("INVALID_PACKET", false)
+			return
+		}
+		if err := s.introduce(client, req); err != nil {
+			log.WithFields(log.Fields{"PlayerID": client.ConnectedPlayer.ID, "PeerID": req.PeerID, "error": err.Error()}).Debug("Introduction refused")
+			client.RespondError(err.Error(), false)
+		}
+	case messages.Introduction.Result:
+		var res PunchResultPacket
+		if err := packet.ReadPayload(&res); err != nil {
+			client.RespondError("INVALID_PACKET", false)
+			return
+		}
+		s.punchReported(client.ConnectedPlayer.ID, res)
+	default:
+		log.WithFields(log.Fields{"Flag": strconv.FormatInt((int64)(packet.Flag), 16), "IP": client.IP}).Warn("Flag not recognized")
+		client.RespondError("FLAG_NOT_RECOGNIZED", false)
+	}
+}
+
+// introduce sends both peers the endpoints of the other, a new request for the same pair replaces the previous attempt.
+// The returned error is the reason sent back to the client.
+func (s *GameServer) introduce(client *Client, req PunchRequestPacket) error {
+	peer := s.clientByID(req.PeerID)
+	if peer == nil || peer == client || !s.samePeerLobby(client, peer) {
+		return fmt.Errorf("PEER_NOT_FOUND")
+	}
+	if client.UDPAddr == nil {
+		return fmt.Errorf("UDP_NOT_BOUND")
+	}
+	if peer.UDPAddr == nil {
+		return fmt.Errorf("PEER_UDP_NOT_BOUND")
+	}
+
+	// the introduction reaches each peer after about half its round trip, both start punching when the slower one got it
+	lead := max(client.RTT(), peer.RTT())/2 + punchMargin
+	intro := &introduction{token: rand.Uint32(), reported: make(map[int32]bool)}
+	pair := newPeerPair(client.ConnectedPlayer.ID, peer.ConnectedPlayer.ID)
+	s.punches.mu.Lock()
+	if old, ok := s.punches.pairs[pair]; ok && old.timer != nil {
+		old.timer.Stop()
+	}
+	s.punches.pairs[pair] = intro
+	intro.timer = time.AfterFunc(lead+punchTimeout, func() { s.punchTimedOut(pair, intro.token) })
+	s.punches.mu.Unlock()
+
+	client.privateIP, client.privatePort = req.PrivateIP, req.PrivatePort
+	for _, side := range [][2]*Client{{client, peer}, {peer, client}} {
+		to, other := side[0], side[1]
+		// a peer that never asked for an introduction didn't tell its private endpoint, it gets the public one twice
+		privateIP, privatePort := other.privateIP, other.privatePort
+		if privateIP == "" {
+			privateIP, privatePort = other.UDPAddr.IP.String(), int32(other.UDPAddr.Port)
+		}
+		pac := to.NewPacket(messages.Punch, messages.Introduction.Introduce)
+		err := pac.AddToPayload(&PunchIntroductionPacket{
+			PeerID:      other.ConnectedPlayer.ID,
+			Token:       intro.token,
+			PublicIP:    other.UDPAddr.IP.String(),
+			PublicPort:  int32(other.UDPAddr.Port),
+			PrivateIP:   privateIP,
+			PrivatePort: privatePort,
+			Delay:       int32((lead - to.RTT()/2) / time.Millisecond),
+		})
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to add introduction to packet")
+			continue
+		}
+		if err := to.Send(&pac); err != nil {
+			log.WithFields(log.Fields{"IP": to.IP, "err": err}).Debug("Failed to send introduction")
+		}
+	}
+	log.WithFields(log.Fields{"PlayerID": client.ConnectedPlayer.ID, "PeerID": peer.ConnectedPlayer.ID, "Delay": lead}).Debug("Introduced peers")
+	return nil
+}
+
+// punchReported records the result of one side. The pair is done once both succeeded, a failure on either side starts the relay.
+func (s *GameServer) punchReported(id int32, res PunchResultPacket) {
+	pair := newPeerPair(id, res.PeerID)
+	s.punches.mu.Lock()
+	intro, ok := s.punches.pairs[pair]
+	if !ok || intro.token != res.Token || intro.relaying {
+		s.punches.mu.Unlock()
+		return
+	}
+	intro.reported[id] = res.Success
+	success := res.Success && len(intro.reported) == 2 && intro.reported[pair.low] && intro.reported[pair.high]
+	if !res.Success {
+		intro.timer.Stop()
+		intro.relaying = true
+	} else if success {
+		intro.timer.Stop()
+		delete(s.punches.pairs, pair)
+	}
+	s.punches.mu.Unlock()
+
+	switch {
+	case success:
+		log.WithFields(log.Fields{"PlayerID": pair.low, "PeerID": pair.high}).Debug("Peers connected directly")
+		s.sendPunchResult(pair, intro.token, true)
+	case !res.Success:
+		log.WithFields(log.Fields{"PlayerID": id, "PeerID": res.PeerID}).Debug("Punching failed, relaying")
+		s.sendPunchResult(pair, intro.token, false)
+	}
+}
+
+func (s *GameServer) punchTimedOut(pair peerPair, token uint32) {
+	s.punches.mu.Lock()
+	intro, ok := s.punches.pairs[pair]
+	if !ok || intro.token != token || intro.relaying {
+		s.punches.mu.Unlock()
+		return
+	}
+	intro.relaying = true
+	s.punches.mu.Unlock()
+	log.WithFields(log.Fields{"PlayerID": pair.low, "PeerID": pair.high}).Debug("Punching timed out, relaying")
+	s.sendPunchResult(pair, token, false)
+}
+
+func (s *GameServer) sendPunchResult(pair peerPair, token uint32, success bool) {
+	for _, ids := range [][2]int32{{pair.low, pair.high}, {pair.high, pair.low}} {
+		c := s.clientByID(ids[0])
+		if c == nil {
+			continue
+		}
+		pac := c.NewPacket(messages.Punch, messages.Introduction.Result)
+		if err := pac.AddToPayload(&PunchResultPacket{PeerID: ids[1], Token: token, Success: success, Relayed: !success}); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to add punch result to packet")
+			continue
+		}
+		if err := c.Send(&pac); err != nil {
+			log.WithFields(log.Fields{"IP": c.IP, "err": err}).Debug("Failed to send punch result")
+		}
+	}
+}
+
+// forgetIntroductions drops every pair a player is part of, it stops relaying for them
+func (s *GameServer) forgetIntroductions(id int32) {
+	s.punches.mu.Lock()
+	defer s.punches.mu.Unlock()
+	for pair, intro := range s.punches.pairs {
+		if pair.low == id || pair.high == id {
+			if intro.timer != nil {
+				intro.timer.Stop()
+			}
+			delete(s.punches.pairs, pair)
+		}
+	}
+}
+
+func (s *GameServer) relaying(a, b int32) bool {
+	s.punches.mu.Lock()
+	defer s.punches.mu.Unlock()
+	intro, ok := s.punches.pairs[newPeerPair(a, b)]
+	return ok && intro.relaying
+}
+
+// relay forwards a Relay packet to the peer it is addressed to, only for pairs whose punching failed
+func (s *GameServer) relay(client *Client, packet *Packet) error {
+	var hdr RelayHeader
+	if err := packet.ReadPayload(&hdr); err != nil {
+		return err
+	}
+	if len(packet.Payload) < relayHeaderSize {
+		return fmt.Errorf("relay packet too short")
+	}
+	if !s.relaying(client.ConnectedPlayer.ID, hdr.PeerID) {
+		return fmt.Errorf("no relay to player %d", hdr.PeerID)
+	}
+	peer := s.clientByID(hdr.PeerID)
+	if peer == nil || !s.samePeerLobby(client, peer) {
+		return fmt.Errorf("peer %d is gone", hdr.PeerID)
+	}
+	fwd := Packet{Header: messages.Relay, Flag: packet.Flag}
+	if err := fwd.AddToPayload(&RelayHeader{PeerID: client.ConnectedPlayer.ID}); err != nil {
+		return err
+	}
+	fwd.Payload = append(fwd.Payload, packet.Payload[relayHeaderSize:]...)
+	return s.SendUDP(peer, &fwd)
+}
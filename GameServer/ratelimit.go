@@ -0,0 +1,289 @@
+package GameServer
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"MonophobiaServer/messages"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Rate classes group packets that are limited together
+const (
+	RateCreateLobby = "create_lobby"
+	RateLobbyList   = "lobby_list"
+	RateJoinLobby   = "join_lobby"
+	RateGameplay    = "gameplay"  // transforms, items, interactions
+	RateAck         = "ack"       // Ack and FragmentReceived, one per message or fragment received from the server
+	RateTransport   = "transport" // Fragment, Sequenced and Relay envelopes, the packets inside count on their own
+	RateControl     = "control"   // everything else
+)
+
+// RateLimit is a token bucket: Rate tokens per second, up to Burst saved up
+type RateLimit struct {
+	Rate  float64
+	Burst float64
+}
+
+// DefaultRateLimits apply to every client on its own
+var DefaultRateLimits = map[string]RateLimit{
+	RateCreateLobby: {1, 3},
+	RateLobbyList:   {5, 10},
+	RateJoinLobby:   {2, 5},
+	RateGameplay:    {60, 120},
+	RateAck:         {500, 1000},
+	RateTransport:   {300, 600},
+	RateControl:     {20, 40},
+}
+
+// DefaultIPRateLimits apply to all clients from one IP together
+var DefaultIPRateLimits = map[string]RateLimit{
+	RateCreateLobby: {2, 6},
+	RateLobbyList:   {10, 20},
+	RateJoinLobby:   {5, 10},
+	RateGameplay:    {240, 480},
+	RateAck:         {2000, 4000},
+	RateTransport:   {1200, 2400},
+	RateControl:     {60, 120},
+}
+
+var DefaultConnectRate = RateLimit{5, 10}
+
+const (
+	// a client whose packets got dropped this often within rateWindow is warned, then disconnected
+	rateWarnAfter       = 20
+	rateDisconnectAfter = 100
+	rateWindow          = 10 * time.Second
+	// rateIdle is how long an IP's buckets are kept after its last packet
+	rateIdle = time.Minute
+)
+
+func (l RateLimit) String() string {
+	return strconv.FormatFloat(l.Rate, 'f', -1, 64) + "/" + strconv.FormatFloat(l.Burst, 'f', -1, 64)
+}
+
+// ParseRateLimit reads RATE/BURST, like 5/10. Without a burst it is the same as the rate.
+func ParseRateLimit(s string) (RateLimit, error) {
+	rate, burst, found := strings.Cut(strings.TrimSpace(s), "/")
+	var l RateLimit
+	var err error
+	if l.Rate, err = strconv.ParseFloat(rate, 64); err != nil || l.Rate <= 0 {
+		return l, fmt.Errorf("invalid rate %q", s)
+	}
+	l.Burst = max(l.Rate, 1)
+	if found {
+		if l.Burst, err = strconv.ParseFloat(burst, 64); err != nil || l.Burst < 1 {
+			return l, fmt.Errorf("invalid burst %q", s)
+		}
+	}
+	return l, nil
+}
+
+// ParseRateLimits reads comma separated CLASS=RATE/BURST overrides on top of defaults
+func ParseRateLimits(s string, defaults map[string]RateLimit) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit, len(defaults))
+	for class, l := range defaults {
+		limits[class] = l
+	}
+	for _, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		class, value, ok := strings.Cut(entry, "=")
+		class = strings.TrimSpace(class)
+		if _, known := defaults[class]; !ok || !known {
+			return nil, fmt.Errorf("invalid rate limit %q, expected CLASS=RATE/BURST with a class of %s", entry, strings.Join(rateClasses(), ", "))
+		}
+		l, err := ParseRateLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		limits[class] = l
+	}
+	return limits, nil
+}
+
+func rateClasses() []string {
+	return []string{RateCreateLobby, RateLobbyList, RateJoinLobby, RateGameplay, RateAck, RateTransport, RateControl}
+}
+
+// rateClass is the class a packet from a client counts against
+func rateClass(packet *Packet) string {
+	switch packet.Header {
+	case messages.Ack:
+		return RateAck
+	case messages.Fragment, messages.Sequenced, messages.Relay:
+		return RateTransport
+	case messages.Data:
+	default:
+		return RateControl
+	}
+	switch packet.Flag {
+	case messages.Post.CreateLobby:
+		return RateCreateLobby
+	case messages.Request.LobbyList:
+		return RateLobbyList
+	case messages.Post.JoinLobby, messages.Post.JoinLobbyByCode:
+		return RateJoinLobby
+	case messages.Post.PlayerTransformData, messages.Post.ItemPickup, messages.Post.ItemDrop, messages.Post.ItemIntInf:
+		return RateGameplay
+	case messages.Response.FragmentReceived:
+		return RateAck
+	}
+	return RateControl
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take(limit RateLimit, now time.Time) bool {
+	b.tokens = min(limit.Burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// rateLimiter keeps a bucket per key, a key is a class, optionally prefixed by who is limited
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*tokenBucket)}
+}
+
+// allow takes a token for key, classes without a limit are always allowed
+func (r *rateLimiter) allow(key string, limit RateLimit, now time.Time) bool {
+	if r == nil || limit.Rate <= 0 {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: limit.Burst, last: now}
+		r.buckets[key] = b
+	}
+	return b.take(limit, now)
+}
+
+// sweep forgets buckets that weren't used for a while, they would be full again anyway
+func (r *rateLimiter) sweep(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, b := range r.buckets {
+		if now.Sub(b.last) > rateIdle {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+// clientRate is the limiter of one client and how often it went over lately
+type clientRate struct {
+	limiter     *rateLimiter
+	mu          sync.Mutex
+	windowStart time.Time
+	drops       int
+	warned      bool
+}
+
+func newClientRate() *clientRate {
+	return &clientRate{limiter: newRateLimiter()}
+}
+
+// dropped counts a dropped packet and tells whether the client should now be warned or disconnected
+func (c *clientRate) dropped(now time.Time) (warn, disconnect bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.windowStart) > rateWindow {
+		c.windowStart = now
+		c.drops = 0
+		c.warned = false
+	}
+	c.drops++
+	if c.drops >= rateDisconnectAfter {
+		return false, true
+	}
+	if c.drops >= rateWarnAfter && !c.warned {
+		c.warned = true
+		return true, false
+	}
+	return false, false
+}
+
+func (s *GameServer) rateLimits() map[string]RateLimit {
+	if s.RateLimits == nil {
+		return DefaultRateLimits
+	}
+	return s.RateLimits
+}
+
+func (s *GameServer) ipRateLimits() map[string]RateLimit {
+	if s.IPRateLimits == nil {
+		return DefaultIPRateLimits
+	}
+	return s.IPRateLimits
+}
+
+// allowPacket applies the client's and its IP's limits. Packets over the limit are dropped,
+// a client that keeps going is warned with a RATE_LIMITED error and then disconnected.
+func (s *GameServer) allowPacket(client *Client, packet *Packet) bool {
+	if client.rate == nil {
+		return true
+	}
+	now := time.Now()
+	class := rateClass(packet)
+	if client.rate.limiter.allow(class, s.rateLimits()[class], now) && s.ipLimiter.allow(client.IP+"|"+class, s.ipRateLimits()[class], now) {
+		return true
+	}
+	warn, disconnect := client.rate.dropped(now)
+	switch {
+	case disconnect:
+		log.WithFields(log.Fields{"IP": client.IP, "Class": class}).Warn("Client kept flooding, disconnecting")
+		// a flooder doesn't get to keep its seat for a resume
+		s.markLeaving(client)
+		client.Disconnect("RATE_LIMITED")
+	case warn:
+		log.WithFields(log.Fields{"IP": client.IP, "Class": class}).Debug("Client is being rate limited")
+		client.RespondError("RATE_LIMITED", false)
+	}
+	return false
+}
+
+// allowConnection caps new connections per IP, see ConnectRate
+func (s *GameServer) allowConnection(remote string) bool {
+	limit := s.ConnectRate
+	if limit.Rate == 0 {
+		limit = DefaultConnectRate
+	}
+	ip := remote
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		ip = host
+	}
+	if s.connectLimiter.allow(ip, limit, time.Now()) {
+		return true
+	}
+	log.WithField("IP", ip).Debug("Too many connections, refusing")
+	return false
+}
+
+// rateJanitor forgets the buckets of IPs that went quiet
+func (s *GameServer) rateJanitor() {
+	ticker := time.NewTicker(rateIdle)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.ipLimiter.sweep(now)
+		s.connectLimiter.sweep(now)
+	}
+}
@@ -0,0 +1,451 @@
+package GameServer
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"MonophobiaServer/messages"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// reliableWindow bounds both unacknowledged packets on the way out and out of order packets buffered on the way in
+	reliableWindow = 1024
+	// reliableMaxBuffered bounds the bytes of out of order packets buffered per client
+	reliableMaxBuffered = 256 * 1024
+	reliableInitialRTO  = 250 * time.Millisecond
+	reliableMinRTO      = 50 * time.Millisecond
+	reliableMaxRTO      = 2 * time.Second
+	reliableGiveUpAfter = 10 * time.Second
+	reliabilityInterval = 10 * time.Millisecond
+
+	// sequencedHeaderSize is the encoded size of SequencedHeader
+	sequencedHeaderSize = 2
+)
+
+// SequencedHeader starts the payload of a Sequenced packet, the full frame of the wrapped packet follows it
+type SequencedHeader struct {
+	Sequence uint16
+}
+
+// AckPacket acknowledges Sequence and, for every bit i set in AckBits, Sequence-1-i.
+// It is sent as an Ack packet with the channel as flag.
+type AckPacket struct {
+	Sequence uint16
+	AckBits  uint32
+}
+
+// seqNewer reports whether sequence a comes after b, taking wrap around into account
+func seqNewer(a, b uint16) bool {
+	return int16(a-b) > 0
+}
+
+type reliableSent struct {
+	packet        *Packet
+	firstSent     time.Time
+	sentAt        time.Time
+	retransmitted bool
+}
+
+// reliableEndpoint is the per client state of the sequenced UDP channels
+type reliableEndpoint struct {
+	mu sync.Mutex
+
+	// unreliable sequenced
+	seqOut  uint16
+	seqIn   uint16
+	seqInit bool
+
+	// reliable ordered, sending side
+	relOut  uint16
+	unacked map[uint16]*reliableSent
+	srtt    time.Duration
+	rttvar  time.Duration
+	rto     time.Duration
+	hasRTT  bool
+
+	// reliable ordered, receiving side
+	relExpected uint16
+	ackSeq      uint16
+	ackBits     uint32
+	ackInit     bool
+	buffered    map[uint16][]byte
+	bufferedLen int // bytes in buffered
+}
+
+func newReliableEndpoint() *reliableEndpoint {
+	return &reliableEndpoint{
+		unacked:  make(map[uint16]*reliableSent),
+		buffered: make(map[uint16][]byte),
+		rto:      reliableInitialRTO,
+	}
+}
+
+// acceptSequenced drops unreliable sequenced packets older than the newest one seen
+func (e *reliableEndpoint) acceptSequenced(seq uint16) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.seqInit && !seqNewer(seq, e.seqIn) {
+		return false
+	}
+	e.seqIn = seq
+	e.seqInit = true
+	return true
+}
+
+// recordReceived updates the ack state with a reliable sequence number that arrived
+func (e *reliableEndpoint) recordReceived(seq uint16) {
+	if !e.ackInit {
+		e.ackSeq = seq
+		e.ackBits = 0
+		e.ackInit = true
+		return
+	}
+	if seqNewer(seq, e.ackSeq) {
+		shift := seq - e.ackSeq
+		if shift > 32 {
+			e.ackBits = 0
+		} else {
+			e.ackBits = e.ackBits<<shift | 1<<(shift-1)
+		}
+		e.ackSeq = seq
+		return
+	}
+	if d := e.ackSeq - seq; d >= 1 && d <= 32 {
+		e.ackBits |= 1 << (d - 1)
+	}
+}
+
+// receiveReliable stores a reliable packet and returns the ack to send plus every frame that is now in order
+func (e *reliableEndpoint) receiveReliable(seq uint16, frame []byte) (AckPacket, [][]byte, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if uint16(seq-e.relExpected) >= reliableWindow {
+		if seqNewer(e.relExpected, seq) {
+			// already delivered, the ack got lost so send it again
+			e.recordReceived(seq)
+			return AckPacket{e.ackSeq, e.ackBits}, nil, true
+		}
+		// too far ahead, don't ack so the sender tries again later
+		return AckPacket{}, nil, false
+	}
+	if _, dup := e.buffered[seq]; dup {
+		e.recordReceived(seq)
+		return AckPacket{e.ackSeq, e.ackBits}, nil, true
+	}
+	// the packet everyone waits for always gets in, it frees what is buffered behind it
+	if seq != e.relExpected && e.bufferedLen+len(frame) > reliableMaxBuffered {
+		// don't ack so the sender tries again once the gap is filled
+		return AckPacket{}, nil, false
+	}
+	e.recordReceived(seq)
+	ack := AckPacket{e.ackSeq, e.ackBits}
+	e.buffered[seq] = append([]byte{}, frame...)
+	e.bufferedLen += len(frame)
+
+	var deliver [][]byte
+	for {
+		f, ok := e.buffered[e.relExpected]
+		if !ok {
+			break
+		}
+		deliver = append(deliver, f)
+		e.bufferedLen -= len(f)
+		delete(e.buffered, e.relExpected)
+		e.relExpected++
+	}
+	return ack, deliver, true
+}
+
+// nextReliable registers an envelope for retransmission and returns its sequence number
+func (e *reliableEndpoint) nextReliable() (uint16, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.unacked) >= reliableWindow {
+		return 0, fmt.Errorf("too many unacknowledged reliable packets")
+	}
+	seq := e.relOut
+	e.relOut++
+	return seq, nil
+}
+
+func (e *reliableEndpoint) track(seq uint16, envelope *Packet, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.unacked[seq] = &reliableSent{packet: envelope, firstSent: now, sentAt: now}
+}
+
+func (e *reliableEndpoint) nextSequenced() uint16 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	seq := e.seqOut
+	e.seqOut++
+	return seq
+}
+
+func (e *reliableEndpoint) onAck(ack AckPacket, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ackOne(ack.Sequence, now)
+	for i := uint16(0); i < 32; i++ {
+		if ack.AckBits&(1<<i) != 0 {
+			e.ackOne(ack.Sequence-1-i, now)
+		}
+	}
+}
+
+func (e *reliableEndpoint) ackOne(seq uint16, now time.Time) {
+	sent, ok := e.unacked[seq]
+	if !ok {
+		return
+	}
+	// Karn's algorithm, a retransmitted packet's ack can't tell which copy it belongs to
+	if !sent.retransmitted {
+		e.sampleRTT(now.Sub(sent.sentAt))
+	}
+	delete(e.unacked, seq)
+}
+
+// sampleRTT updates the retransmission timeout the way RFC 6298 does
+func (e *reliableEndpoint) sampleRTT(r time.Duration) {
+	if !e.hasRTT {
+		e.srtt = r
+		e.rttvar = r / 2
+		e.hasRTT = true
+	} else {
+		diff := e.srtt - r
+		if diff < 0 {
+			diff = -diff
+		}
+		e.rttvar = (3*e.rttvar + diff) / 4
+		e.srtt = (7*e.srtt + r) / 8
+	}
+	e.rto = min(max(e.srtt+4*e.rttvar, reliableMinRTO), reliableMaxRTO)
+}
+
+// RTT returns the smoothed round trip time measured on the reliable channel, 0 before the first sample
+func (e *reliableEndpoint) RTT() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.srtt
+}
+
+// due returns the envelopes whose timeout passed. expired is set when a packet went unacknowledged for too long.
+func (e *reliableEndpoint) due(now time.Time) (resend []*Packet, expired int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for seq, sent := range e.unacked {
+		if now.Sub(sent.firstSent) > reliableGiveUpAfter {
+			delete(e.unacked, seq)
+			expired++
+			continue
+		}
+		if now.Sub(sent.sentAt) >= e.rto {
+			sent.sentAt = now
+			sent.retransmitted = true
+			resend = append(resend, sent.packet)
+		}
+	}
+	if len(resend) > 0 {
+		// back off while packets keep getting lost
+		e.rto = min(e.rto*2, reliableMaxRTO)
+	}
+	return resend, expired
+}
+
+// SendChannel sends a packet to the client over UDP on the given channel:
+// messages.None for plain unreliable, or one of messages.Channel.
+// Clients without a bound UDP address get the packet over TCP instead, which is reliable and ordered anyway,
+// except that unreliable packets are the first to be dropped when the client's send queue fills up.
+func (s *GameServer) SendChannel(client *Client, packet *Packet, channel messages.Flag) error {
+	if client.UDPAddr == nil || client.udp == nil {
+		if channel == messages.Channel.ReliableOrdered {
+			return client.Send(packet)
+		}
+		return client.SendUnreliable(packet)
+	}
+	if channel == messages.None {
+		return s.SendUDP(client, packet)
+	}
+
+	frame, err := packet.assembleMessage()
+	if err != nil {
+		return err
+	}
+	var seq uint16
+	switch channel {
+	case messages.Channel.UnreliableSequenced:
+		seq = client.udp.nextSequenced()
+	case messages.Channel.ReliableOrdered:
+		if seq, err = client.udp.nextReliable(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown channel 0x%02x", channel)
+	}
+
+	envelope := &Packet{Header: messages.Sequenced, Flag: channel}
+	if err := envelope.AddToPayload(&SequencedHeader{seq}); err != nil {
+		return err
+	}
+	envelope.Payload = append(envelope.Payload, frame...)
+	if channel == messages.Channel.ReliableOrdered {
+		client.udp.track(seq, envelope, time.Now())
+	}
+	return s.SendUDP(client, envelope)
+}
+
+// receiveSequenced unwraps a Sequenced packet and returns the frames that should be parsed now, in order
+func (s *GameServer) receiveSequenced(client *Client, packet *Packet) ([][]byte, error) {
+	if client.udp == nil {
+		return nil, fmt.Errorf("client has no UDP channels")
+	}
+	var hdr SequencedHeader
+	if err := packet.ReadPayload(&hdr); err != nil {
+		return nil, err
+	}
+	if len(packet.Payload) < sequencedHeaderSize {
+		return nil, fmt.Errorf("sequenced packet too short")
+	}
+	frame := packet.Payload[sequencedHeaderSize:]
+
+	switch packet.Flag {
+	case messages.Channel.UnreliableSequenced:
+		if !client.udp.acceptSequenced(hdr.Sequence) {
+			return nil, nil
+		}
+		return [][]byte{frame}, nil
+	case messages.Channel.ReliableOrdered:
+		ack, frames, ok := client.udp.receiveReliable(hdr.Sequence, frame)
+		if ok {
+			ackPacket := Packet{Header: messages.Ack, Flag: packet.Flag}
+			if err := ackPacket.AddToPayload(&ack); err != nil {
+				return nil, err
+			}
+			if err := s.SendUDP(client, &ackPacket); err != nil {
+				log.WithFields(log.Fields{"error": err.Error(), "PlayerID": client.ConnectedPlayer.ID}).Debug("Failed to send ack")
+			}
+		}
+		return frames, nil
+	}
+	return nil, fmt.Errorf("unknown channel 0x%02x", packet.Flag)
+}
+
+// handleUDPPacket authenticates a datagram from a bound client and handles what is in it.
+// Nothing, not even a fragment, counts for the client before it was opened.
+func (s *GameServer) handleUDPPacket(client *Client, addr *net.UDPAddr, packet *Packet) {
+	if err := s.openFrame(client, packet, true); err != nil {
+		log.WithFields(log.Fields{"error": err.Error(), "IP": addr.IP.String()}).Debug("Dropping UDP packet")
+		return
+	}
+	client.heartbeat.alive()
+	s.dispatchUDP(client, addr, packet)
+}
+
+// dispatchUDP takes an opened packet apart (fragments, channels, acks) and parses what is inside
+func (s *GameServer) dispatchUDP(client *Client, addr *net.UDPAddr, packet *Packet) {
+	switch packet.Header {
+	case messages.Fragment, messages.Sequenced, messages.Relay, messages.Ack:
+		// these never reach ParsePacket, which limits everything else
+		if !s.allowPacket(client, packet) {
+			return
+		}
+	}
+	switch packet.Header {
+	case messages.Fragment:
+		frame, err := s.handleFragment(client, addr, packet)
+		if err != nil {
+			log.WithFields(log.Fields{"error": err.Error(), "IP": addr.IP.String()}).Debug("Dropping fragment")
+			return
+		}
+		if frame == nil {
+			return
+		}
+		// the fragments were authenticated one by one, the frame they add up to is plaintext
+		inner := &Packet{}
+		if err := inner.DigestData(&frame); err != nil || inner.Header == messages.Fragment || inner.frameFlags&FrameEncrypted != 0 {
+			log.WithFields(log.Fields{"IP": addr.IP.String()}).Debug("Invalid reassembled packet")
+			return
+		}
+		s.dispatchUDP(client, addr, inner)
+	case messages.Sequenced:
+		frames, err := s.receiveSequenced(client, packet)
+		if err != nil {
+			log.WithFields(log.Fields{"error": err.Error(), "IP": addr.IP.String()}).Debug("Dropping sequenced packet")
+			return
+		}
+		for _, frame := range frames {
+			inner := &Packet{}
+			if err := inner.DigestData(&frame); err != nil || inner.Header == messages.Fragment || inner.Header == messages.Sequenced || inner.frameFlags&FrameEncrypted != 0 {
+				log.WithFields(log.Fields{"IP": addr.IP.String()}).Debug("Invalid sequenced packet")
+				continue
+			}
+			inner.Client = client
+			s.ParsePacket(inner)
+		}
+	case messages.Relay:
+		if err := s.relay(client, packet); err != nil {
+			log.WithFields(log.Fields{"error": err.Error(), "IP": addr.IP.String()}).Debug("Dropping relay packet")
+		}
+	case messages.Ack:
+		var ack AckPacket
+		if err := packet.ReadPayload(&ack); err != nil || client.udp == nil {
+			return
+		}
+		client.udp.onAck(ack, time.Now())
+	default:
+		packet.Client = client
+		s.ParsePacket(packet)
+	}
+}
+
+func (s *GameServer) registerEndpoint(client *Client) {
+	s.endpointsMu.Lock()
+	defer s.endpointsMu.Unlock()
+	client.udp = newReliableEndpoint()
+	s.endpoints[client] = struct{}{}
+}
+
+func (s *GameServer) unregisterEndpoint(client *Client) {
+	s.endpointsMu.Lock()
+	defer s.endpointsMu.Unlock()
+	delete(s.endpoints, client)
+}
+
+// reliabilityLoop retransmits reliable packets that weren't acknowledged in time
+func (s *GameServer) reliabilityLoop() {
+	ticker := time.NewTicker(reliabilityInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.retransmit(now)
+	}
+}
+
+func (s *GameServer) retransmit(now time.Time) {
+	s.endpointsMu.Lock()
+	clients := make([]*Client, 0, len(s.endpoints))
+	for cl := range s.endpoints {
+		clients = append(clients, cl)
+	}
+	s.endpointsMu.Unlock()
+
+	for _, cl := range clients {
+		resend, expired := cl.udp.due(now)
+		if expired > 0 {
+			// the client holds back everything after the lost packet until it arrives, which it never will now
+			log.WithFields(log.Fields{"PlayerID": cl.ConnectedPlayer.ID, "Count": expired}).Warn("Reliable UDP packets were never acknowledged, disconnecting")
+			s.unregisterEndpoint(cl)
+			s.markLeaving(cl)
+			cl.Disconnect("RELIABLE_TIMEOUT")
+			continue
+		}
+		for _, envelope := range resend {
+			if err := s.SendUDP(cl, envelope); err != nil {
+				log.WithFields(log.Fields{"error": err.Error(), "PlayerID": cl.ConnectedPlayer.ID}).Debug("Failed to retransmit")
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package GameServer
+
+import (
+	"testing"
+	"time"
+
+	"MonophobiaServer/messages"
+)
+
+func TestReceiveReliableBufferLimit(t *testing.T) {
+	e := newReliableEndpoint()
+	frame := make([]byte, 1024)
+	// sequence 0 never arrives, everything after it has to be buffered
+	seq := uint16(1)
+	for ; ; seq++ {
+		if _, _, ok := e.receiveReliable(seq, frame); !ok {
+			break
+		}
+	}
+	if e.bufferedLen > reliableMaxBuffered {
+		t.Fatalf("buffered %d bytes, limit is %d", e.bufferedLen, reliableMaxBuffered)
+	}
+	if _, delivered, ok := e.receiveReliable(0, frame); !ok || len(delivered) != int(seq) {
+		t.Fatalf("filling the gap delivered %d frames, want %d", len(delivered), seq)
+	}
+	if e.bufferedLen != 0 || len(e.buffered) != 0 {
+		t.Fatalf("%d bytes in %d frames left buffered", e.bufferedLen, len(e.buffered))
+	}
+	// the dropped one is accepted once it is sent again
+	if _, delivered, ok := e.receiveReliable(seq, frame); !ok || len(delivered) != 1 {
+		t.Fatal("retransmission of the dropped packet wasn't delivered")
+	}
+}
+
+func TestReliableGiveUpDisconnects(t *testing.T) {
+	s := newTestServer(t)
+	conn := NewPipeConnection("lossy", 8)
+	c := s.ConnectLocal(conn, "lossy")
+	s.registerEndpoint(c)
+	start := time.Now()
+	seq, err := c.udp.nextReliable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.udp.track(seq, &Packet{Header: messages.Sequenced, Flag: messages.Channel.ReliableOrdered}, start)
+
+	s.retransmit(start.Add(reliableGiveUpAfter / 2))
+	if len(conn.Packets()) != 0 || c.leaving {
+		t.Fatal("client was dropped before the give up time")
+	}
+	// the packet is never acknowledged
+	s.retransmit(start.Add(reliableGiveUpAfter + time.Second))
+	p, ok := conn.Receive()
+	var reason ErrorPacket
+	if !ok || p.Header != messages.Disconnecting || p.ReadPayload(&reason) != nil || reason.Message != "RELIABLE_TIMEOUT" {
+		t.Fatalf("got %v/%d %+v, want a RELIABLE_TIMEOUT disconnect", p.Header, p.Flag, reason)
+	}
+	if _, ok := conn.Receive(); ok {
+		t.Fatal("connection is still open")
+	}
+	if _, ok := s.endpoints[c]; ok || !c.leaving {
+		t.Fatal("client would keep its channels or its seat")
+	}
+}
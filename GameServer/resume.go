@@ -0,0 +1,171 @@
+package GameServer
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"slices"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultResumeGrace is how long a player whose connection dropped keeps its seat
+	DefaultResumeGrace = 30 * time.Second
+	resumeTokenSize    = 32
+)
+
+type suspendedClient struct {
+	client *Client
+	timer  *time.Timer
+}
+
+func newResumeToken() ([]byte, error) {
+	token := make([]byte, resumeTokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// suspend parks a client whose connection dropped instead of removing it, so it can resume within ResumeGrace.
+// The player stays in its lobby, marked as reconnecting. Returns false if the client has to be removed instead.
+func (s *GameServer) suspend(c *Client) bool {
+	s.suspendedMu.Lock()
+	if c.replaced {
+		// the player lives on in the connection that resumed it, there is nothing left to clean up
+		s.suspendedMu.Unlock()
+		return true
+	}
+	if c.resumeToken == nil || c.leaving || s.ResumeGrace <= 0 {
+		s.suspendedMu.Unlock()
+		return false
+	}
+	pl := c.ConnectedPlayer
+	pl.Reconnecting = true
+	key := string(c.resumeToken)
+	s.suspended[key] = &suspendedClient{c, time.AfterFunc(s.ResumeGrace, func() { s.expireSuspended(key) })}
+	s.suspendedMu.Unlock()
+
+	log.WithFields(log.Fields{"name": pl.Name, "id": pl.ID, "grace": s.ResumeGrace}).Debug("Player disconnected, keeping seat")
+	if pl.Lobby != nil {
+		// the lobby can't wait for its owner to come back
+		if pl.Lobby.Owner == pl {
+			pl.Lobby.migrateOwner(pl)
+		}
+		pl.Lobby.BroadcastInfo()
+	}
+	return true
+}
+
+// markLeaving keeps the client's seat from being kept for a resume once its connection ends
+func (s *GameServer) markLeaving(c *Client) {
+	s.suspendedMu.Lock()
+	c.leaving = true
+	s.suspendedMu.Unlock()
+}
+
+func (s *GameServer) expireSuspended(key string) {
+	s.suspendedMu.Lock()
+	sc, ok := s.suspended[key]
+	delete(s.suspended, key)
+	s.suspendedMu.Unlock()
+	if !ok {
+		return
+	}
+	log.WithFields(log.Fields{"name": sc.client.ConnectedPlayer.Name, "id": sc.client.ConnectedPlayer.ID}).Debug("Player did not come back in time")
+	sc.client.ConnectedPlayer.Reconnecting = false
+	s.removeClient(sc.client)
+}
+
+// resume returns the client the token belongs to, nil if there is none (anymore).
+// Clients often reconnect before the server noticed their old connection died, then the old client is still live.
+// It is marked as replaced and the caller has to close it once the player was taken over.
+func (s *GameServer) resume(token []byte) *Client {
+	if len(token) == 0 {
+		return nil
+	}
+	s.suspendedMu.Lock()
+	defer s.suspendedMu.Unlock()
+	if sc, ok := s.suspended[string(token)]; ok {
+		// if the timer already fired the client is being removed right now
+		if !sc.timer.Stop() {
+			return nil
+		}
+		delete(s.suspended, string(token))
+		return sc.client
+	}
+	old := s.clientByToken(token)
+	if old == nil || old.leaving {
+		return nil
+	}
+	old.replaced = true
+	return old
+}
+
+func (s *GameServer) clientByToken(token []byte) *Client {
+	s.clientsMu.RLock()
+	defer s.clientsMu.RUnlock()
+	for _, c := range s.Clients {
+		if c.ConnectedPlayer != nil && c.resumeToken != nil && subtle.ConstantTimeCompare(c.resumeToken, token) == 1 {
+			return c
+		}
+	}
+	return nil
+}
+
+// takeOver moves the player of a suspended client, with its lobby seat and UDP binding, to the client that resumed it.
+// The UDP binding only carries over if the client comes back from the same IP, otherwise it has to send ImHere again.
+func (s *GameServer) takeOver(old, c *Client) {
+	pl := old.ConnectedPlayer
+	c.ConnectedPlayer = pl
+	pl.NetworkClient = c
+	pl.Reconnecting = false
+
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	if old.UDPAddr != nil {
+		key := udpKey(old.UDPAddr)
+		if old.IP == c.IP {
+			c.UDPPort = old.UDPPort
+			c.UDPAddr = old.UDPAddr
+			c.udpConn = old.udpConn
+			c.udp = old.udp
+			s.UDPConnectionMap[key] = c
+			s.endpointsMu.Lock()
+			delete(s.endpoints, old)
+			s.endpoints[c] = struct{}{}
+			s.endpointsMu.Unlock()
+		} else {
+			delete(s.UDPConnectionMap, key)
+			s.reassembler.Forget(old.UDPAddr.String())
+			s.unregisterEndpoint(old)
+		}
+	}
+
+	if i := slices.Index(s.Clients, old); i >= 0 {
+		s.Clients[i] = c
+	} else {
+		s.Clients = append(s.Clients, c)
+	}
+}
+
+// removeClient is the cleanup for a client that is gone for good
+func (s *GameServer) removeClient(c *Client) {
+	log.WithFields(log.Fields{"name": c.ConnectedPlayer.Name, "id": c.ConnectedPlayer.ID}).Trace("Player disconnected")
+	s.forgetIntroductions(c.ConnectedPlayer.ID)
+	s.clientsMu.Lock()
+	if c.UDPAddr != nil {
+		delete(s.UDPConnectionMap, udpKey(c.UDPAddr))
+		s.reassembler.Forget(c.UDPAddr.String())
+		s.unregisterEndpoint(c)
+	}
+	s.Clients = slices.DeleteFunc(s.Clients, func(n *Client) bool {
+		return n == c
+	})
+	s.clientsMu.Unlock()
+
+	if c.ConnectedPlayer.Lobby != nil {
+		c.ConnectedPlayer.Lobby.RemovePlayer(c.ConnectedPlayer)
+	}
+}
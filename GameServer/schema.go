@@ -0,0 +1,277 @@
+package GameServer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+
+	"MonophobiaServer/messages"
+)
+
+// Flag groups, named after the variables in messages
+const (
+	GroupNone         = ""
+	GroupRequest      = "Request"
+	GroupPost         = "Post"
+	GroupResponse     = "Response"
+	GroupHeartbeat    = "Heartbeat"
+	GroupBind         = "Bind"
+	GroupIntroduction = "Introduction"
+)
+
+// PayloadBinding ties a packet to the struct carried in its payload.
+// Payload is a pointer to a zero value of the struct, nil for packets without payload or with a hand written one.
+type PayloadBinding struct {
+	Header  messages.Header
+	Group   string
+	Flag    messages.Flag
+	Payload interface{}
+}
+
+// PayloadBindings has to be kept in sync with the handlers, it is what the schema export (and so the client) is generated from
+var PayloadBindings = []PayloadBinding{
+	{messages.Hello, GroupNone, messages.None, &HelloPacket{}},
+	{messages.ImHere, GroupBind, messages.Bind.Request, &ImHerePacket{}},
+	{messages.ImHere, GroupBind, messages.Bind.Challenge, &BindChallengePacket{}},
+	{messages.ImHere, GroupBind, messages.Bind.Response, &BindResponsePacket{}},
+	{messages.ImHere, GroupBind, messages.Bind.Bound, &ImHerePacket{}},
+	{messages.Punch, GroupIntroduction, messages.Introduction.Request, &PunchRequestPacket{}},
+	{messages.Punch, GroupIntroduction, messages.Introduction.Introduce, &PunchIntroductionPacket{}},
+	{messages.Punch, GroupIntroduction, messages.Introduction.Result, &PunchResultPacket{}},
+	{messages.Relay, GroupNone, messages.None, &RelayHeader{}},
+	{messages.Echo, GroupHeartbeat, messages.Heartbeat.Ping, &HeartbeatPacket{}},
+	{messages.Echo, GroupHeartbeat, messages.Heartbeat.Pong, &HeartbeatPacket{}},
+	{messages.Rejected, GroupNone, messages.None, &ErrorPacket{}},
+	{messages.Disconnecting, GroupNone, messages.None, &ErrorPacket{}},
+	{messages.Fragment, GroupNone, messages.None, &FragmentHeader{}},
+	{messages.Sequenced, GroupNone, messages.None, &SequencedHeader{}},
+	{messages.Ack, GroupNone, messages.None, &AckPacket{}},
+
+	{messages.Data, GroupRequest, messages.Request.LobbyList, &LobbyListRequestPacket{}},
+	{messages.Data, GroupPost, messages.Post.CreateLobby, &CreateLobbyPacket{}},
+	{messages.Data, GroupPost, messages.Post.JoinLobby, &JoinLobbyPacket{}},
+	{messages.Data, GroupPost, messages.Post.PlayerTransformData, &PlayerTransformPacket{}},
+	{messages.Data, GroupPost, messages.Post.TransferOwnership, &TransferOwnershipPacket{}},
+	{messages.Data, GroupPost, messages.Post.UpdateLobbyInfo, &UpdateLobbyInfoPacket{}},
+	{messages.Data, GroupPost, messages.Post.JoinLobbyByCode, &JoinLobbyByCodePacket{}},
+	{messages.Data, GroupPost, messages.Post.KickPlayer, &KickPlayerPacket{}},
+	{messages.Data, GroupPost, messages.Post.BanPlayer, &KickPlayerPacket{}},
+
+	{messages.Data, GroupResponse, messages.Response.IDAssign, &IDAssignPacket{}},
+	{messages.Data, GroupResponse, messages.Response.LobbyInfo, &NetworkLobbyInfo{}},
+	{messages.Data, GroupResponse, messages.Response.LobbyList, &LobbyListPacket{}},
+	{messages.Data, GroupResponse, messages.Response.LobbyListChanged, nil},
+	{messages.Data, GroupResponse, messages.Response.Kicked, &KickedPacket{}},
+	{messages.Data, GroupResponse, messages.Response.PlayerTransforms, &PlayerTransformsPacket{}},
+	{messages.Data, GroupResponse, messages.Response.FragmentReceived, &FragmentAckPacket{}},
+}
+
+type Schema struct {
+	ProtocolVersion    int32              `json:"protocolVersion"`
+	MinProtocolVersion int32              `json:"minProtocolVersion"`
+	Headers            []SchemaConstant   `json:"headers"`
+	Flags              []SchemaFlagGroup  `json:"flags"`
+	Frame              SchemaFrame        `json:"frame"`
+	FrameFlags         []SchemaBit        `json:"frameFlags"`
+	Capabilities       []SchemaBit        `json:"capabilities"`
+	Packets            []SchemaPacket     `json:"packets"`
+	Types              []SchemaStructType `json:"types"`
+}
+
+// SchemaFrame is the layout of the frame header every packet starts with
+type SchemaFrame struct {
+	HeaderSize int    `json:"headerSize"`
+	Layout     string `json:"layout"`
+	Length     string `json:"length"`
+	// LengthVersion is the first protocol version the server sends the full frame length to
+	LengthVersion int32 `json:"lengthVersion"`
+}
+
+type SchemaConstant struct {
+	Name  string `json:"name"`
+	Value uint16 `json:"value"`
+}
+
+// SchemaBit is one bit of a bitset, like the frame flags in the length field or a messages.Capability
+type SchemaBit struct {
+	Name  string `json:"name"`
+	Value uint32 `json:"value"`
+}
+
+type SchemaFlagGroup struct {
+	Name  string           `json:"name"`
+	Flags []SchemaConstant `json:"flags"`
+}
+
+type SchemaPacket struct {
+	Header  string `json:"header"`
+	Group   string `json:"group,omitempty"`
+	Flag    string `json:"flag,omitempty"`
+	Payload string `json:"payload,omitempty"`
+}
+
+type SchemaStructType struct {
+	Name   string        `json:"name"`
+	Fields []SchemaField `json:"fields"`
+}
+
+// SchemaField describes a field as it goes on the wire. Type is written Go style: int32, []T, [4]T, map[K]V, *T
+type SchemaField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Max      int    `json:"max,omitempty"`
+	Wire     string `json:"wire,omitempty"`
+	Since    int32  `json:"since,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// flagGroups returns the flags of messages.Request/Post/Response in declaration order
+func flagGroups() []SchemaFlagGroup {
+	groups := []struct {
+		name  string
+		value interface{}
+	}{
+		{GroupRequest, messages.Request},
+		{GroupPost, messages.Post},
+		{GroupResponse, messages.Response},
+		{GroupHeartbeat, messages.Heartbeat},
+		{GroupBind, messages.Bind},
+		{GroupIntroduction, messages.Introduction},
+	}
+	var result []SchemaFlagGroup
+	for _, g := range groups {
+		v := reflect.ValueOf(g.value)
+		group := SchemaFlagGroup{Name: g.name}
+		for i := 0; i < v.NumField(); i++ {
+			group.Flags = append(group.Flags, SchemaConstant{v.Type().Field(i).Name, uint16(v.Field(i).Uint())})
+		}
+		result = append(result, group)
+	}
+	return result
+}
+
+func capabilities() []SchemaBit {
+	v := reflect.ValueOf(messages.Capabilities)
+	var result []SchemaBit
+	for i := 0; i < v.NumField(); i++ {
+		result = append(result, SchemaBit{v.Type().Field(i).Name, uint32(v.Field(i).Uint())})
+	}
+	return result
+}
+
+func flagName(groups []SchemaFlagGroup, group string, flag messages.Flag) string {
+	for _, g := range groups {
+		if g.Name != group {
+			continue
+		}
+		for _, f := range g.Flags {
+			if f.Value == uint16(flag) {
+				return f.Name
+			}
+		}
+	}
+	return fmt.Sprintf("0x%02X", uint8(flag))
+}
+
+// ExportSchema describes every header, flag and payload struct the server knows about
+func ExportSchema() (*Schema, error) {
+	schema := &Schema{ProtocolVersion: ProtocolVersion, MinProtocolVersion: MinProtocolVersion}
+	for _, h := range messages.Headers {
+		schema.Headers = append(schema.Headers, SchemaConstant{h.String(), uint16(h)})
+	}
+	schema.Flags = flagGroups()
+	schema.Frame = SchemaFrame{
+		HeaderSize:    HeaderSize,
+		LengthVersion: FrameLengthVersion,
+		Layout:        "header uint16 big endian, flag uint8, length uint32 little endian, payload",
+		Length: fmt.Sprintf("bytes of the whole frame including the header, the frame flags are or'ed into the top bits. "+
+			"The server sends only the payload length to clients below protocol %d and to everyone until their Hello was read", FrameLengthVersion),
+	}
+	schema.FrameFlags = []SchemaBit{{"Compressed", FrameCompressed}, {"Encrypted", FrameEncrypted}}
+	schema.Capabilities = capabilities()
+
+	types := map[string]*SchemaStructType{}
+	var order []string
+	for _, b := range PayloadBindings {
+		packet := SchemaPacket{Header: b.Header.String(), Group: b.Group}
+		if b.Group != GroupNone {
+			packet.Flag = flagName(schema.Flags, b.Group, b.Flag)
+		}
+		if b.Payload != nil {
+			t := reflect.TypeOf(b.Payload).Elem()
+			packet.Payload = t.Name()
+			if err := describeStruct(t, types, &order); err != nil {
+				return nil, fmt.Errorf("%s: %w", t.Name(), err)
+			}
+		}
+		schema.Packets = append(schema.Packets, packet)
+	}
+	slices.Sort(order)
+	for _, name := range order {
+		schema.Types = append(schema.Types, *types[name])
+	}
+	return schema, nil
+}
+
+// describeStruct adds t and every struct it references to types
+func describeStruct(t reflect.Type, types map[string]*SchemaStructType, order *[]string) error {
+	if _, ok := types[t.Name()]; ok {
+		return nil
+	}
+	st := &SchemaStructType{Name: t.Name()}
+	types[t.Name()] = st
+	*order = append(*order, t.Name())
+
+	fields, err := wireFields(t)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		typeName, err := describeType(t.Field(f.index).Type, types, order)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+		st.Fields = append(st.Fields, SchemaField{f.name, typeName, f.tag.Max, f.tag.Wire, f.tag.Since, f.tag.Optional})
+	}
+	return nil
+}
+
+func describeType(t reflect.Type, types map[string]*SchemaStructType, order *[]string) (string, error) {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String:
+		// named types like messages.Flag are described by their underlying kind
+		return t.Kind().String(), nil
+	case reflect.Struct:
+		if t.Name() == "" {
+			return "", fmt.Errorf("anonymous structs can't be exported, give it a name")
+		}
+		return t.Name(), describeStruct(t, types, order)
+	case reflect.Pointer:
+		elem, err := describeType(t.Elem(), types, order)
+		return "*" + elem, err
+	case reflect.Slice:
+		elem, err := describeType(t.Elem(), types, order)
+		return "[]" + elem, err
+	case reflect.Array:
+		elem, err := describeType(t.Elem(), types, order)
+		return fmt.Sprintf("[%d]%s", t.Len(), elem), err
+	case reflect.Map:
+		key, err := describeType(t.Key(), types, order)
+		if err != nil {
+			return "", err
+		}
+		elem, err := describeType(t.Elem(), types, order)
+		return "map[" + key + "]" + elem, err
+	}
+	return "", fmt.Errorf("unsupported type %v", t)
+}
+
+func (s *Schema) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
@@ -0,0 +1,388 @@
+package GameServer
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// C# equivalents of the wire types, with the BinaryReader method that reads them.
+// BinaryWriter.Write has an overload for each of them, and both are little endian like the server.
+var csharpBasics = map[string]struct {
+	name string
+	read string
+}{
+	"bool":    {"bool", "ReadBoolean"},
+	"int8":    {"sbyte", "ReadSByte"},
+	"int16":   {"short", "ReadInt16"},
+	"int32":   {"int", "ReadInt32"},
+	"int64":   {"long", "ReadInt64"},
+	"uint8":   {"byte", "ReadByte"},
+	"byte":    {"byte", "ReadByte"},
+	"uint16":  {"ushort", "ReadUInt16"},
+	"uint32":  {"uint", "ReadUInt32"},
+	"uint64":  {"ulong", "ReadUInt64"},
+	"float32": {"float", "ReadSingle"},
+	"float64": {"double", "ReadDouble"},
+}
+
+const csharpWireHelpers = `    public static class MonoWire
+    {
+        public static void WriteString(BinaryWriter w, string v)
+        {
+            byte[] b = Encoding.UTF8.GetBytes(v ?? "");
+            w.Write(b.Length);
+            w.Write(b);
+        }
+
+        public static string ReadString(BinaryReader r)
+        {
+            int n = r.ReadInt32();
+            return Encoding.UTF8.GetString(r.ReadBytes(n));
+        }
+    }
+`
+
+type csharpWriter struct {
+	w     io.Writer
+	err   error
+	depth int
+}
+
+func (cw *csharpWriter) line(indent int, format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+	_, cw.err = fmt.Fprintf(cw.w, strings.Repeat("    ", indent)+format+"\n", args...)
+}
+
+// WriteCSharp writes a C# file with the headers, flags and payload classes of the schema.
+// Every payload class gets Write/Read methods that produce the same bytes as the server.
+func (s *Schema) WriteCSharp(w io.Writer, namespace string) error {
+	cw := &csharpWriter{w: w}
+	cw.line(0, "// Code generated by MonophobiaServer schema. DO NOT EDIT.")
+	cw.line(0, "using System;")
+	cw.line(0, "using System.Collections.Generic;")
+	cw.line(0, "using System.IO;")
+	cw.line(0, "using System.Text;")
+	cw.line(0, "")
+	cw.line(0, "namespace %s", namespace)
+	cw.line(0, "{")
+	cw.line(1, "public static class MonoProtocol")
+	cw.line(1, "{")
+	cw.line(2, "public const int Version = %d;", s.ProtocolVersion)
+	cw.line(2, "public const int MinVersion = %d;", s.MinProtocolVersion)
+	cw.line(2, "// %s", s.Frame.Layout)
+	cw.line(2, "// length: %s", s.Frame.Length)
+	cw.line(2, "public const int FrameHeaderSize = %d;", s.Frame.HeaderSize)
+	cw.line(2, "public const int FrameLengthVersion = %d;", s.Frame.LengthVersion)
+	for _, f := range s.FrameFlags {
+		cw.line(2, "public const uint Frame%s = 0x%08X;", f.Name, f.Value)
+	}
+	cw.line(1, "}")
+	cw.line(0, "")
+
+	cw.line(1, "[Flags]")
+	cw.line(1, "public enum Capability : uint")
+	cw.line(1, "{")
+	cw.line(2, "None = 0,")
+	for _, c := range s.Capabilities {
+		cw.line(2, "%s = 0x%X,", c.Name, c.Value)
+	}
+	cw.line(1, "}")
+	cw.line(0, "")
+
+	cw.line(1, "public enum Header : ushort")
+	cw.line(1, "{")
+	for _, h := range s.Headers {
+		cw.line(2, "%s = 0x%04X,", h.Name, h.Value)
+	}
+	cw.line(1, "}")
+	for _, g := range s.Flags {
+		cw.line(0, "")
+		cw.line(1, "public static class %s", g.Name)
+		cw.line(1, "{")
+		for _, f := range g.Flags {
+			cw.line(2, "public const byte %s = 0x%02X;", f.Name, f.Value)
+		}
+		cw.line(1, "}")
+	}
+
+	usedBy := map[string][]string{}
+	for _, p := range s.Packets {
+		if p.Payload == "" {
+			continue
+		}
+		name := p.Header
+		if p.Group != GroupNone {
+			name += "/" + p.Group + "." + p.Flag
+		}
+		usedBy[p.Payload] = append(usedBy[p.Payload], name)
+	}
+
+	for _, t := range s.Types {
+		cw.line(0, "")
+		if packets, ok := usedBy[t.Name]; ok {
+			cw.line(1, "/// <summary>Payload of %s</summary>", strings.Join(packets, ", "))
+		}
+		cw.writeClass(t)
+	}
+	cw.line(0, "")
+	if cw.err == nil {
+		_, cw.err = io.WriteString(w, csharpWireHelpers)
+	}
+	cw.line(0, "}")
+	return cw.err
+}
+
+func (cw *csharpWriter) writeClass(t SchemaStructType) {
+	cw.line(1, "public class %s", t.Name)
+	cw.line(1, "{")
+	for _, f := range t.Fields {
+		cs, err := csharpType(f.Type)
+		if err != nil {
+			cw.err = fmt.Errorf("%s.%s: %w", t.Name, f.Name, err)
+			return
+		}
+		var comment []string
+		if f.Max > 0 {
+			comment = append(comment, "max "+strconv.Itoa(f.Max))
+		}
+		if f.Since > 0 {
+			comment = append(comment, "since protocol "+strconv.Itoa(int(f.Since)))
+		}
+		if f.Optional {
+			comment = append(comment, "optional")
+		}
+		suffix := ""
+		if len(comment) > 0 {
+			suffix = " // " + strings.Join(comment, ", ")
+		}
+		cw.line(2, "public %s %s%s;%s", cs, f.Name, csharpInitializer(f.Type), suffix)
+	}
+
+	cw.line(0, "")
+	cw.line(2, "public void Write(BinaryWriter w, int version = MonoProtocol.Version)")
+	cw.line(2, "{")
+	for _, f := range t.Fields {
+		indent := 3
+		if f.Since > 0 {
+			cw.line(3, "if (version >= %d)", f.Since)
+			cw.line(3, "{")
+			indent = 4
+		}
+		cw.depth = 0
+		cw.writeValue(indent, f.Name, f.Type, f.Wire)
+		if f.Since > 0 {
+			cw.line(3, "}")
+		}
+	}
+	cw.line(2, "}")
+
+	cw.line(0, "")
+	cw.line(2, "public static %s Read(BinaryReader r, int version = MonoProtocol.Version)", t.Name)
+	cw.line(2, "{")
+	cw.line(3, "var x = new %s();", t.Name)
+	for _, f := range t.Fields {
+		indent := 3
+		if f.Since > 0 {
+			cw.line(3, "if (version >= %d)", f.Since)
+			cw.line(3, "{")
+			indent = 4
+		}
+		if f.Optional {
+			cw.line(indent, "if (r.BaseStream.Position == r.BaseStream.Length) return x;")
+		}
+		cw.depth = 0
+		cw.readValue(indent, "x."+f.Name, f.Type, f.Wire)
+		if f.Since > 0 {
+			cw.line(3, "}")
+		}
+	}
+	cw.line(3, "return x;")
+	cw.line(2, "}")
+	cw.line(1, "}")
+}
+
+// splitType breaks a schema type into its outer kind ("*", "[]", "[N]", "map" or "" for named types) and element types
+func splitType(t string) (kind string, key string, elem string) {
+	switch {
+	case strings.HasPrefix(t, "*"):
+		return "*", "", t[1:]
+	case strings.HasPrefix(t, "[]"):
+		return "[]", "", t[2:]
+	case strings.HasPrefix(t, "["):
+		end := strings.Index(t, "]")
+		return t[:end+1], "", t[end+1:]
+	case strings.HasPrefix(t, "map["):
+		// keys are always basic types, so the first ] closes the key
+		end := strings.Index(t, "]")
+		return "map", t[4:end], t[end+1:]
+	}
+	return "", "", t
+}
+
+func csharpType(t string) (string, error) {
+	kind, key, elem := splitType(t)
+	switch {
+	case kind == "":
+		if b, ok := csharpBasics[t]; ok {
+			return b.name, nil
+		}
+		if t == "string" {
+			return "string", nil
+		}
+		return t, nil
+	case kind == "*":
+		cs, err := csharpType(elem)
+		if _, ok := csharpBasics[elem]; ok {
+			cs += "?"
+		}
+		return cs, err
+	case kind == "[]":
+		cs, err := csharpType(elem)
+		return "List<" + cs + ">", err
+	case kind == "map":
+		k, err := csharpType(key)
+		if err != nil {
+			return "", err
+		}
+		v, err := csharpType(elem)
+		return "Dictionary<" + k + ", " + v + ">", err
+	default:
+		cs, err := csharpType(elem)
+		return cs + "[]", err
+	}
+}
+
+func csharpInitializer(t string) string {
+	kind, _, elem := splitType(t)
+	cs, _ := csharpType(t)
+	switch {
+	case kind == "" && t == "string":
+		return " = \"\""
+	case kind == "" && csharpBasics[t].name == "":
+		return " = new " + cs + "()"
+	case kind == "[]" || kind == "map":
+		return " = new " + cs + "()"
+	case strings.HasPrefix(kind, "["):
+		elemCs, _ := csharpType(elem)
+		return fmt.Sprintf(" = new %s[%s]", elemCs, kind[1:len(kind)-1])
+	}
+	return ""
+}
+
+func (cw *csharpWriter) next() int {
+	cw.depth++
+	return cw.depth
+}
+
+func (cw *csharpWriter) writeValue(indent int, expr, t, wire string) {
+	kind, key, elem := splitType(t)
+	switch {
+	case kind == "" && t == "string":
+		cw.line(indent, "MonoWire.WriteString(w, %s);", expr)
+	case kind == "":
+		if _, ok := csharpBasics[t]; !ok {
+			cw.line(indent, "%s.Write(w, version);", expr)
+		} else if wire != "" {
+			cw.line(indent, "w.Write((%s)%s);", csharpBasics[wire].name, expr)
+		} else {
+			cw.line(indent, "w.Write(%s);", expr)
+		}
+	case kind == "*":
+		cw.line(indent, "w.Write(%s != null);", expr)
+		cw.line(indent, "if (%s != null)", expr)
+		cw.line(indent, "{")
+		if _, ok := csharpBasics[elem]; ok {
+			expr += ".Value"
+		}
+		cw.writeValue(indent+1, expr, elem, wire)
+		cw.line(indent, "}")
+	case kind == "[]":
+		e := fmt.Sprintf("e%d", cw.next())
+		cw.line(indent, "w.Write(%s.Count);", expr)
+		cw.line(indent, "foreach (var %s in %s)", e, expr)
+		cw.line(indent, "{")
+		cw.writeValue(indent+1, e, elem, wire)
+		cw.line(indent, "}")
+	case kind == "map":
+		d := cw.next()
+		keyCs, _ := csharpType(key)
+		cw.line(indent, "w.Write(%s.Count);", expr)
+		cw.line(indent, "var keys%d = new List<%s>(%s.Keys);", d, keyCs, expr)
+		if key == "string" {
+			cw.line(indent, "keys%d.Sort(string.CompareOrdinal);", d)
+		} else {
+			cw.line(indent, "keys%d.Sort();", d)
+		}
+		cw.line(indent, "foreach (var k%d in keys%d)", d, d)
+		cw.line(indent, "{")
+		cw.writeValue(indent+1, fmt.Sprintf("k%d", d), key, "")
+		cw.writeValue(indent+1, fmt.Sprintf("%s[k%d]", expr, d), elem, wire)
+		cw.line(indent, "}")
+	default:
+		i := fmt.Sprintf("i%d", cw.next())
+		cw.line(indent, "for (int %s = 0; %s < %s; %s++)", i, i, kind[1:len(kind)-1], i)
+		cw.line(indent, "{")
+		cw.writeValue(indent+1, expr+"["+i+"]", elem, wire)
+		cw.line(indent, "}")
+	}
+}
+
+func (cw *csharpWriter) readValue(indent int, target, t, wire string) {
+	kind, key, elem := splitType(t)
+	switch {
+	case kind == "" && t == "string":
+		cw.line(indent, "%s = MonoWire.ReadString(r);", target)
+	case kind == "":
+		b, ok := csharpBasics[t]
+		if !ok {
+			cw.line(indent, "%s = %s.Read(r, version);", target, t)
+		} else if wire != "" {
+			cw.line(indent, "%s = (%s)r.%s();", target, b.name, csharpBasics[wire].read)
+		} else {
+			cw.line(indent, "%s = r.%s();", target, b.read)
+		}
+	case kind == "*":
+		cw.line(indent, "%s = null;", target)
+		cw.line(indent, "if (r.ReadBoolean())")
+		cw.line(indent, "{")
+		cw.readValue(indent+1, target, elem, wire)
+		cw.line(indent, "}")
+	case kind == "[]":
+		d := cw.next()
+		elemCs, _ := csharpType(elem)
+		cw.line(indent, "int n%d = r.ReadInt32();", d)
+		cw.line(indent, "%s = new List<%s>(n%d);", target, elemCs, d)
+		cw.line(indent, "for (int i%d = 0; i%d < n%d; i%d++)", d, d, d, d)
+		cw.line(indent, "{")
+		cw.line(indent+1, "%s e%d;", elemCs, d)
+		cw.readValue(indent+1, fmt.Sprintf("e%d", d), elem, wire)
+		cw.line(indent+1, "%s.Add(e%d);", target, d)
+		cw.line(indent, "}")
+	case kind == "map":
+		d := cw.next()
+		keyCs, _ := csharpType(key)
+		elemCs, _ := csharpType(elem)
+		cw.line(indent, "int n%d = r.ReadInt32();", d)
+		cw.line(indent, "%s = new Dictionary<%s, %s>(n%d);", target, keyCs, elemCs, d)
+		cw.line(indent, "for (int i%d = 0; i%d < n%d; i%d++)", d, d, d, d)
+		cw.line(indent, "{")
+		cw.line(indent+1, "%s k%d;", keyCs, d)
+		cw.line(indent+1, "%s v%d;", elemCs, d)
+		cw.readValue(indent+1, fmt.Sprintf("k%d", d), key, "")
+		cw.readValue(indent+1, fmt.Sprintf("v%d", d), elem, wire)
+		cw.line(indent+1, "%s[k%d] = v%d;", target, d, d)
+		cw.line(indent, "}")
+	default:
+		i := fmt.Sprintf("i%d", cw.next())
+		elemCs, _ := csharpType(elem)
+		cw.line(indent, "%s = new %s[%s];", target, elemCs, kind[1:len(kind)-1])
+		cw.line(indent, "for (int %s = 0; %s < %s.Length; %s++)", i, i, target, i)
+		cw.line(indent, "{")
+		cw.readValue(indent+1, target+"["+i+"]", elem, wire)
+		cw.line(indent, "}")
+	}
+}
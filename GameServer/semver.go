@@ -0,0 +1,168 @@
+package GameServer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Semver is a MAJOR.MINOR.PATCH game version. Pre-release and build suffixes are not used by the game and are rejected.
+type Semver struct {
+	Major, Minor, Patch int
+}
+
+func ParseSemver(s string) (Semver, error) {
+	var v Semver
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("invalid version %q, expected MAJOR.MINOR.PATCH", s)
+	}
+	for i, dst := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		*dst = n
+	}
+	return v, nil
+}
+
+func (v Semver) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1, 0 or 1 like strings.Compare
+func (v Semver) Compare(o Semver) int {
+	for _, d := range [][2]int{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if d[0] != d[1] {
+			if d[0] < d[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+type versionBound struct {
+	version   Semver
+	inclusive bool
+}
+
+// VersionRange is a set of client versions the server accepts, written as space or comma separated conditions that all have to hold:
+//
+//	>=0.1.0 <0.3.0
+//	^0.1.1   same as >=0.1.1 <0.2.0 (the first non zero part may not change)
+//	~0.1.1   same as >=0.1.1 <0.2.0 (minor may not change)
+//	0.1.1    exactly that version
+type VersionRange struct {
+	text  string
+	lower *versionBound
+	upper *versionBound
+}
+
+func ParseVersionRange(s string) (VersionRange, error) {
+	r := VersionRange{text: s}
+	fields := strings.FieldsFunc(s, func(c rune) bool { return c == ' ' || c == ',' })
+	if len(fields) == 0 {
+		return r, fmt.Errorf("empty version range")
+	}
+	for _, f := range fields {
+		op := strings.TrimRight(f, "0123456789.v")
+		v, err := ParseSemver(f[len(op):])
+		if err != nil {
+			return r, err
+		}
+		switch op {
+		case ">=":
+			r.atLeast(versionBound{v, true})
+		case ">":
+			r.atLeast(versionBound{v, false})
+		case "<=":
+			r.atMost(versionBound{v, true})
+		case "<":
+			r.atMost(versionBound{v, false})
+		case "", "=":
+			r.atLeast(versionBound{v, true})
+			r.atMost(versionBound{v, true})
+		case "^":
+			r.atLeast(versionBound{v, true})
+			switch {
+			case v.Major > 0:
+				r.atMost(versionBound{Semver{v.Major + 1, 0, 0}, false})
+			case v.Minor > 0:
+				r.atMost(versionBound{Semver{0, v.Minor + 1, 0}, false})
+			default:
+				r.atMost(versionBound{Semver{0, 0, v.Patch + 1}, false})
+			}
+		case "~":
+			r.atLeast(versionBound{v, true})
+			r.atMost(versionBound{Semver{v.Major, v.Minor + 1, 0}, false})
+		default:
+			return r, fmt.Errorf("unknown operator %q in version range %q", op, s)
+		}
+	}
+	if r.lower != nil && r.upper != nil {
+		c := r.lower.version.Compare(r.upper.version)
+		if c > 0 || (c == 0 && !(r.lower.inclusive && r.upper.inclusive)) {
+			return r, fmt.Errorf("version range %q matches no version", s)
+		}
+	}
+	return r, nil
+}
+
+// atLeast tightens the lower bound
+func (r *VersionRange) atLeast(b versionBound) {
+	if r.lower == nil {
+		r.lower = &b
+		return
+	}
+	c := b.version.Compare(r.lower.version)
+	if c > 0 || (c == 0 && !b.inclusive) {
+		r.lower = &b
+	}
+}
+
+// atMost tightens the upper bound
+func (r *VersionRange) atMost(b versionBound) {
+	if r.upper == nil {
+		r.upper = &b
+		return
+	}
+	c := b.version.Compare(r.upper.version)
+	if c < 0 || (c == 0 && !b.inclusive) {
+		r.upper = &b
+	}
+}
+
+func (r VersionRange) Contains(v Semver) bool {
+	if r.lower != nil {
+		c := v.Compare(r.lower.version)
+		if c < 0 || (c == 0 && !r.lower.inclusive) {
+			return false
+		}
+	}
+	if r.upper != nil {
+		c := v.Compare(r.upper.version)
+		if c > 0 || (c == 0 && !r.upper.inclusive) {
+			return false
+		}
+	}
+	return true
+}
+
+// Min is the oldest version the range accepts, empty if it has no lower bound.
+// For an exclusive bound (>0.1.0) it is written with a > in front since there's no single oldest version.
+func (r VersionRange) Min() string {
+	if r.lower == nil {
+		return ""
+	}
+	if r.lower.inclusive {
+		return r.lower.version.String()
+	}
+	return ">" + r.lower.version.String()
+}
+
+func (r VersionRange) String() string {
+	return r.text
+}
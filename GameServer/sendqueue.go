@@ -0,0 +1,251 @@
+package GameServer
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"MonophobiaServer/messages"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultSendQueueSize is how many packets may wait for a client's writer before traffic gets dropped
+	DefaultSendQueueSize = 256
+	// DefaultWriteTimeout is how long one write to a client may block before the client is dropped
+	DefaultWriteTimeout = 5 * time.Second
+)
+
+var (
+	ErrSendQueueFull    = errors.New("send queue full, packet dropped")
+	ErrClientClosed     = errors.New("client connection closed")
+	errSendQueueStalled = errors.New("send queue full of reliable packets")
+)
+
+type queuedPacket struct {
+	packet Packet
+	// compression and encryption are picked when the packet is queued, so capabilities enabled later don't touch packets queued before
+	compressThreshold int
+	sealer            *cipherState
+	fullLength        bool
+	droppable         bool
+}
+
+// sendQueue is the outbound queue of one client. Packets are only assembled (compressed, encrypted) by the writer,
+// that keeps TCP nonces in write order and lets unreliable packets be dropped from the queue.
+type sendQueue struct {
+	mu      sync.Mutex
+	items   []queuedPacket
+	limit   int
+	wake    chan struct{}
+	closing bool // no new packets, the writer stops once the queue is empty
+	err     error
+
+	compressThreshold int
+	crypto            *session
+	fullLength        bool // see FrameLengthVersion
+}
+
+func newSendQueue(limit int) *sendQueue {
+	if limit <= 0 {
+		limit = DefaultSendQueueSize
+	}
+	return &sendQueue{limit: limit, wake: make(chan struct{}, 1)}
+}
+
+func (q *sendQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// push queues a copy of packet. When the queue is full droppable packets are turned away,
+// others push out the oldest droppable one, and if there is none errSendQueueStalled is returned.
+func (q *sendQueue) push(packet *Packet, droppable bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closing {
+		if q.err != nil {
+			return q.err
+		}
+		return ErrClientClosed
+	}
+	if len(q.items) >= q.limit {
+		if droppable {
+			return ErrSendQueueFull
+		}
+		i := slices.IndexFunc(q.items, func(item queuedPacket) bool { return item.droppable })
+		if i < 0 {
+			return errSendQueueStalled
+		}
+		q.items = slices.Delete(q.items, i, i+1)
+	}
+	item := queuedPacket{packet: *packet, compressThreshold: q.compressThreshold, fullLength: q.fullLength, droppable: droppable}
+	if q.crypto != nil {
+		item.sealer = q.crypto.tcpSend
+	}
+	q.items = append(q.items, item)
+	q.signal()
+	return nil
+}
+
+// next blocks until there is a packet to write, false once the queue is closed and empty
+func (q *sendQueue) next() (queuedPacket, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			item := q.items[0]
+			q.items = q.items[1:]
+			q.mu.Unlock()
+			return item, true
+		}
+		closing := q.closing
+		q.mu.Unlock()
+		if closing {
+			return queuedPacket{}, false
+		}
+		<-q.wake
+	}
+}
+
+// close lets the writer finish what is queued and stop. With last the queue is emptied first and only last is still sent.
+func (q *sendQueue) close(last *Packet) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closing {
+		return
+	}
+	if last != nil {
+		q.items = q.items[:0]
+		item := queuedPacket{packet: *last, compressThreshold: q.compressThreshold, fullLength: q.fullLength}
+		if q.crypto != nil {
+			item.sealer = q.crypto.tcpSend
+		}
+		q.items = append(q.items, item)
+	}
+	q.closing = true
+	q.signal()
+}
+
+func (q *sendQueue) fail(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closing = true
+	q.err = err
+	q.items = nil
+}
+
+// streamConnection is the Connection of TCP and WebSocket clients: a send queue in front of a writer goroutine
+type streamConnection struct {
+	conn  net.Conn
+	queue *sendQueue
+}
+
+func newStreamConnection(conn net.Conn, queueSize int) *streamConnection {
+	return &streamConnection{conn: conn, queue: newSendQueue(queueSize)}
+}
+
+// writeLoop drains the queue into conn and closes conn when done.
+// A write that doesn't finish within timeout ends the connection, which the reading side picks up as a disconnect.
+func (t *streamConnection) writeLoop(timeout time.Duration) {
+	defer t.conn.Close()
+	if timeout <= 0 {
+		timeout = DefaultWriteTimeout
+	}
+	for {
+		item, ok := t.queue.next()
+		if !ok {
+			return
+		}
+		frame, err := item.packet.assembleFrame(item.compressThreshold, item.sealer, item.fullLength)
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to assemble packet")
+			continue
+		}
+		t.conn.SetWriteDeadline(time.Now().Add(timeout))
+		if _, err := t.conn.Write(frame); err != nil {
+			log.WithFields(log.Fields{"IP": t.conn.RemoteAddr().String(), "error": err.Error()}).Debug("Failed writing to client")
+			t.queue.fail(err)
+			return
+		}
+	}
+}
+
+// Send queues a packet, it never blocks on the network
+func (t *streamConnection) Send(packet *Packet) error {
+	return t.queue.push(packet, false)
+}
+
+func (t *streamConnection) SendUnreliable(packet *Packet) error {
+	return t.queue.push(packet, true)
+}
+
+// Close lets the writer send what is still queued, then closes the connection
+func (t *streamConnection) Close() error {
+	t.queue.close(nil)
+	return nil
+}
+
+func (t *streamConnection) RemoteAddr() net.Addr {
+	return t.conn.RemoteAddr()
+}
+
+func (t *streamConnection) closeWith(last *Packet) {
+	t.queue.close(last)
+}
+
+func (t *streamConnection) useSession(compressThreshold int, crypto *session) {
+	t.queue.mu.Lock()
+	defer t.queue.mu.Unlock()
+	t.queue.compressThreshold = compressThreshold
+	t.queue.crypto = crypto
+}
+
+func (t *streamConnection) useFullFrameLength(full bool) {
+	t.queue.mu.Lock()
+	defer t.queue.mu.Unlock()
+	t.queue.fullLength = full
+}
+
+// Send hands a packet to the client's connection. It never blocks on the network,
+// if the client can't keep up and its queue fills with packets that can't be dropped, the client is disconnected.
+func (c *Client) Send(packet *Packet) error {
+	return c.sent(c.Conn.Send(packet))
+}
+
+// SendUnreliable is Send for traffic that may be lost, it is dropped first when the client falls behind
+func (c *Client) SendUnreliable(packet *Packet) error {
+	return c.sent(c.Conn.SendUnreliable(packet))
+}
+
+func (c *Client) sent(err error) error {
+	if errors.Is(err, errSendQueueStalled) {
+		log.WithField("IP", c.IP).Warn("Client can't keep up, disconnecting")
+		c.Disconnect("SEND_QUEUE_FULL")
+		return ErrClientClosed
+	}
+	return err
+}
+
+// Disconnect drops whatever is still queued for the client, tells it why it is being disconnected and closes the connection
+func (c *Client) Disconnect(reason string) {
+	c.DisconnectDetails(reason, nil)
+}
+
+// DisconnectDetails is Disconnect with machine readable details for the client, like RespondErrorDetails
+func (c *Client) DisconnectDetails(reason string, details map[string]string) {
+	pac := c.NewPacket(messages.Disconnecting, messages.None)
+	if err := pac.AddToPayload(&ErrorPacket{Message: reason, Details: details}); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to add error message to packet")
+	}
+	if lc, ok := c.Conn.(lastWordCloser); ok {
+		lc.closeWith(&pac)
+		return
+	}
+	c.Conn.Send(&pac)
+	c.Conn.Close()
+}
@@ -2,13 +2,21 @@ package GameServer
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"math/rand/v2"
 	"net"
+	"net/netip"
 	"os"
 	"os/signal"
 	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"MonophobiaServer/messages"
 
@@ -16,37 +24,195 @@ import (
 )
 
 type GameServer struct {
-	IP               net.IP
+	Addresses        []netip.Addr // listened on for TCP and UDP, empty means every IPv4 and IPv6 address
 	Port             int
+	WebSocketAddress string // host:port of the WebSocket listener for browser clients, empty disables it
 	GameVersion      string
-	Clients          []*Client
+	Versions         VersionRange // client game versions let in, empty means exactly GameVersion
+	MaxFrameSize     int32
+	UDPMTU           int
+	Clients          []*Client // guarded by clientsMu
 	Lobbies          []*Lobby
-	UDPConnectionMap map[string]*Client
+	UDPConnectionMap map[netip.AddrPort]*Client // by udpKey of the client's UDP address, guarded by clientsMu
+	SendQueueSize    int                        // packets queued per client, DefaultSendQueueSize if 0
+	WriteTimeout     time.Duration              // DefaultWriteTimeout if 0
+
+	HeartbeatInterval   time.Duration // DefaultHeartbeatInterval if 0
+	MaxMissedHeartbeats int           // DefaultMaxMissedHeartbeats if 0
+	ResumeGrace         time.Duration // how long a dropped player keeps its seat, 0 disables resuming
+	HostMigration       HostMigration // how lobbies pick a new owner
+
+	// CompressionThreshold is the payload size from which packets to clients that support it get compressed, 0 turns compression off
+	CompressionThreshold int
+	Encryption           EncryptionMode
+
+	RateLimits   map[string]RateLimit // per client and rate class, DefaultRateLimits if nil
+	IPRateLimits map[string]RateLimit // per source IP and rate class, DefaultIPRateLimits if nil
+	ConnectRate  RateLimit            // new connections per IP, DefaultConnectRate if zero
+
+	AccessListPath string // bans and allow list, see AccessList, empty lets everyone in
+
+	// clientsMu guards Clients and UDPConnectionMap, the UDP listeners, the TCP handlers and timers all change them.
+	// Don't send while holding it.
+	clientsMu   sync.RWMutex
+	reassembler *Reassembler
+	fragments   *fragmentSender
+	endpointsMu sync.Mutex
+	endpoints   map[*Client]struct{}
+	suspendedMu sync.Mutex
+	suspended   map[string]*suspendedClient // by resume token
+	bindKey     []byte                      // signs UDP bind challenges
+	punches     introductions
+
+	ipLimiter      *rateLimiter
+	connectLimiter *rateLimiter
+
+	access       atomic.Pointer[AccessList]
+	authFailures atomic.Uint64
 }
 
 type Client struct {
-	Conn            *net.Conn
+	Conn            Connection
 	UDPPort         int
-	IP              string
+	UDPAddr         *net.UDPAddr
+	IP              string // of the TCP connection, IPv4 clients of a dual-stack listener are written as IPv4
 	ConnectedPlayer *Player
+	Capabilities    messages.Capability
+	GameVersion     string
+	ProtocolVersion int32 // negotiated in Hello, handlers can branch on it
+
+	udp               *reliableEndpoint
+	udpConn           *net.UDPConn // the socket UDPAddr was bound on, replies go out of it
+	compressThreshold int
+	fullFrameLength   bool // see FrameLengthVersion
+	crypto            *session
+	heartbeat         *heartbeat
+	rate              *clientRate
+	resumeToken       []byte
+	udpSecret         []byte // proves an ImHere comes from this client
+	privateIP         string // LAN endpoint the client told in its last PunchRequest
+	privatePort       int32
+	leaving           bool // the client said goodbye, don't keep its seat, guarded by suspendedMu
+	replaced          bool // a new connection resumed the player while this one was still up, guarded by suspendedMu
+}
+
+var errEncryptionRequired = errors.New("client doesn't support encryption")
+
+// negotiate picks the capabilities both the client and the server support.
+// For encryption it also does the key exchange, returning the session and the public key that goes back in IDAssign.
+func (s *GameServer) negotiate(c *Client, hello *HelloPacket) (*session, []byte, error) {
+	var supported messages.Capability
+	if s.CompressionThreshold > 0 {
+		supported |= messages.Capabilities.Compression
+	}
+	if s.Encryption != EncryptionOff {
+		supported |= messages.Capabilities.Encryption
+	}
+	c.Capabilities = messages.Capability(hello.Capabilities) & supported
+	if c.Capabilities&messages.Capabilities.Encryption == 0 {
+		if s.Encryption == EncryptionRequired {
+			return nil, nil, errEncryptionRequired
+		}
+		return nil, nil, nil
+	}
+	return newServerSession(hello.PublicKey)
+}
+
+// enableCapabilities starts using the negotiated capabilities, the client knows about them once it has read IDAssign
+func (s *GameServer) enableCapabilities(c *Client, crypto *session) {
+	if c.Capabilities&messages.Capabilities.Compression != 0 {
+		c.compressThreshold = s.CompressionThreshold
+	}
+	c.crypto = crypto
+	if sc, ok := c.Conn.(sessionConnection); ok {
+		sc.useSession(c.compressThreshold, crypto)
+	}
+}
+
+// useFrameLength picks how the length of frames sent to the client is counted, by the protocol version its Hello asked for.
+// It is done before anything is answered to the Hello, so even a rejection can be read.
+func (c *Client) useFrameLength(protocol int32) {
+	c.fullFrameLength = protocol >= FrameLengthVersion
+	if sc, ok := c.Conn.(sessionConnection); ok {
+		sc.useFullFrameLength(c.fullFrameLength)
+	}
+}
+
+// negotiateVersion checks the client's game version against Versions and agrees on a protocol version.
+// If the client is turned away the returned details say what it would take to get in.
+func (s *GameServer) negotiateVersion(c *Client, hello *HelloPacket) (map[string]string, error) {
+	details := map[string]string{
+		"server_version":       s.GameVersion,
+		"min_protocol_version": strconv.FormatInt(int64(MinProtocolVersion), 10),
+	}
+	if s.Versions.String() == "" {
+		details["min_version"] = s.GameVersion
+		if hello.Version != s.GameVersion {
+			return details, fmt.Errorf("version %q is not %q", hello.Version, s.GameVersion)
+		}
+	} else {
+		details["min_version"] = s.Versions.Min()
+		details["supported_versions"] = s.Versions.String()
+		v, err := ParseSemver(hello.Version)
+		if err != nil {
+			return details, err
+		}
+		if !s.Versions.Contains(v) {
+			return details, fmt.Errorf("version %s is not in %s", v, s.Versions)
+		}
+	}
+
+	protocol := hello.ProtocolVersion
+	if protocol == 0 {
+		// clients from before negotiation didn't send it
+		protocol = 1
+	}
+	if protocol < MinProtocolVersion {
+		return details, fmt.Errorf("protocol version %d is older than %d", protocol, MinProtocolVersion)
+	}
+	c.GameVersion = hello.Version
+	c.ProtocolVersion = min(protocol, ProtocolVersion)
+	return nil, nil
+}
+
+// NewPacket starts a packet whose payload is written in the protocol version the client negotiated
+func (c *Client) NewPacket(header messages.Header, flag messages.Flag) Packet {
+	return Packet{Header: header, Flag: flag, Version: c.ProtocolVersion}
+}
+
+// maxFrameSize is MaxFrameSize with the default filled in
+func (s *GameServer) maxFrameSize() int32 {
+	if s.MaxFrameSize <= 0 {
+		return DefaultMaxFrameSize
+	}
+	return s.MaxFrameSize
 }
 
 func (c *Client) RespondError(msg string, disconnect bool) {
-	respPacket := Packet{}
+	c.RespondErrorDetails(msg, nil, disconnect)
+}
+
+// RespondErrorDetails is RespondError with machine readable details for the client, e.g. why it was turned away
+func (c *Client) RespondErrorDetails(msg string, details map[string]string, disconnect bool) {
+	respPacket := c.NewPacket(messages.Rejected, messages.None)
 	if disconnect {
 		respPacket.Header = messages.Disconnecting
-	} else {
-		respPacket.Header = messages.Rejected
 	}
-	respPacket.Flag = messages.None
 
-	respPacket.AddString(msg)
-	respPacket.Send(*c.Conn)
+	if err := respPacket.AddToPayload(&ErrorPacket{Message: msg, Details: details}); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to add error message to packet")
+	}
+	if err := c.Send(&respPacket); err != nil {
+		log.WithFields(log.Fields{"IP": c.IP, "error": err.Error()}).Debug("Failed to send error")
+	}
 }
 
-func newClient() *Client {
+func newClient(conn Connection) *Client {
 	cl := &Client{}
 	cl.UDPPort = -1
+	cl.Conn = conn
+	cl.heartbeat = newHeartbeat()
+	cl.rate = newClientRate()
 	return cl
 }
 
@@ -56,16 +222,96 @@ func newPlayer() *Player {
 	return pl
 }
 
-func (s *GameServer) SetAddress(IP string, Port int) {
-	s.IP = net.ParseIP(IP)
+// SetAddress takes a comma separated list of IPs to listen on, an empty list listens on all of them
+func (s *GameServer) SetAddress(IPs string, Port int) error {
+	s.Addresses = nil
+	for _, ip := range strings.Split(IPs, ",") {
+		ip = strings.TrimSpace(ip)
+		if ip == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(strings.Trim(ip, "[]"))
+		if err != nil {
+			return fmt.Errorf("invalid listen address : %w", err)
+		}
+		s.Addresses = append(s.Addresses, addr.Unmap())
+	}
 	s.Port = Port
+	return nil
+}
+
+// listeners returns the networks and addresses to listen on, suffix is "4", "6" or "" for dual-stack.
+// An IPv4 address only gets an IPv4 socket so 0.0.0.0 doesn't quietly take IPv6 as well.
+func (s *GameServer) listeners() (suffixes []string, addresses []string) {
+	if len(s.Addresses) == 0 {
+		return []string{""}, []string{":" + strconv.Itoa(s.Port)}
+	}
+	for _, addr := range s.Addresses {
+		suffix := "6"
+		if addr.Is4() {
+			suffix = "4"
+		}
+		suffixes = append(suffixes, suffix)
+		addresses = append(addresses, netip.AddrPortFrom(addr, uint16(s.Port)).String())
+	}
+	return suffixes, addresses
+}
+
+// remoteIP is the IP of a stream connection, connections that aren't TCP (like an in-process net.Pipe) go by their address
+func remoteIP(addr net.Addr) string {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.AddrPort().Addr().Unmap().String()
+	}
+	return addr.String()
+}
+
+// udpKey is the UDPConnectionMap key of addr. Dual-stack sockets report IPv4 senders as IPv4-mapped IPv6 addresses, they are unmapped.
+func udpKey(addr *net.UDPAddr) netip.AddrPort {
+	ap := addr.AddrPort()
+	return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port())
+}
+
+// initState sets up everything the server needs before it handles connections, Start does it before listening
+func (s *GameServer) initState() error {
+	s.UDPConnectionMap = make(map[netip.AddrPort]*Client)
+	s.reassembler = NewReassembler(DefaultReassemblyTimeout, DefaultMaxReassemblyBytes)
+	s.fragments = newFragmentSender(DefaultReassemblyTimeout)
+	s.endpoints = make(map[*Client]struct{})
+	s.suspended = make(map[string]*suspendedClient)
+	s.punches.pairs = make(map[peerPair]*introduction)
+	s.ipLimiter = newRateLimiter()
+	s.connectLimiter = newRateLimiter()
+	bindKey, err := newUDPSecret()
+	if err != nil {
+		return fmt.Errorf("failed to create UDP bind key : %w", err)
+	}
+	s.bindKey = bindKey
+	return nil
 }
 
 func (s *GameServer) Start() {
-	go s.bindTCP()
-	go s.bindUDP()
+	if err := s.initState(); err != nil {
+		log.WithField("error", err).Fatal("Failed to start server")
+	}
+	if s.AccessListPath != "" {
+		if err := s.ReloadAccessList(); err != nil {
+			log.WithField("error", err).Fatal("Failed to load access list")
+		}
+		go s.accessListWatcher()
+	}
 
-	s.UDPConnectionMap = make(map[string]*Client)
+	suffixes, addresses := s.listeners()
+	for i, address := range addresses {
+		go s.bindTCP("tcp"+suffixes[i], address)
+		go s.bindUDP("udp"+suffixes[i], address)
+	}
+	if s.WebSocketAddress != "" {
+		go s.bindWebSocket(s.WebSocketAddress)
+	}
+	go s.fragmentJanitor()
+	go s.reliabilityLoop()
+	go s.rateJanitor()
+	go s.authFailureReporter()
 
 	log.Info("Started server!")
 	sigs := make(chan os.Signal, 1)
@@ -75,12 +321,14 @@ func (s *GameServer) Start() {
 	log.Info("Shutting down gracefully...")
 }
 
-func (s *GameServer) bindUDP() {
-	log.WithFields(log.Fields{"server_ip": s.IP, "server_port": s.Port}).Debug("Starting UDP server")
-	address := net.UDPAddr{}
-	address.IP = s.IP
-	address.Port = int(s.Port)
-	ln, err := net.ListenUDP("udp4", &address)
+func (s *GameServer) bindUDP(network, address string) {
+	log.WithFields(log.Fields{"network": network, "address": address}).Debug("Starting UDP server")
+	udpAddress, err := net.ResolveUDPAddr(network, address)
+	if err != nil {
+		log.WithField("error", err).Fatal("Invalid UDP address")
+		return
+	}
+	ln, err := net.ListenUDP(network, udpAddress)
 	if err != nil {
 		log.WithField("error", err).Fatal("Failed to bind UDP port")
 		return
@@ -88,9 +336,8 @@ func (s *GameServer) bindUDP() {
 	defer ln.Close()
 
 	log.Trace("Succesfully bound to UDP port")
-	buf := make([]byte, 2048)
+	buf := make([]byte, 65535)
 	for {
-		clear(buf)
 		msglen, addr, err := ln.ReadFromUDP(buf)
 		if !(msglen > 0) {
 			continue
@@ -101,53 +348,37 @@ func (s *GameServer) bindUDP() {
 		}
 		// log.Info(buf)
 		// buf = buf[:msglen]
-		if bytes.HasPrefix(buf, []byte("holepunch")) {
+		// probes of peers punching (see punch.go) that also hit the server, they only keep NAT mappings open
+		if bytes.HasPrefix(buf[:msglen], []byte("holepunch")) {
 			continue
 		}
 		packet := &Packet{}
-		err = packet.DigestData(&buf)
+		data := buf[:msglen]
+		err = packet.DigestData(&data)
 		if err != nil {
 			log.WithFields(log.Fields{"error": err.Error(), "IP": addr.IP.String()}).Trace("Error digesting udp packet data")
 			continue
 		}
 		//log.Info(packet.Payload)
 		if packet.Header == messages.ImHere {
-			var imHerePacket struct {
-				ID int32
-			}
-			err = packet.ReadPayload(&imHerePacket)
-			if err != nil {
-				log.WithField("error", err.Error()).Debug("Failed to read imhere packet")
-				continue
-			}
-			for _, plc := range s.Clients {
-				if plc.UDPPort == addr.Port && plc.IP == string(addr.IP) {
-					break
-				}
-				if (plc.ConnectedPlayer != nil) && (plc.UDPPort == -1) && (plc.ConnectedPlayer.ID == imHerePacket.ID) {
-					plc.UDPPort = addr.Port
-					s.UDPConnectionMap[strconv.FormatInt((int64)(plc.UDPPort), 10)+":"+plc.IP] = plc
-					log.WithFields(log.Fields{"PlayerID": plc.ConnectedPlayer.ID, "New_UDP_Port": plc.UDPPort}).Trace("Player initialized UDP port")
-					break
-				}
-			}
+			s.handleBind(ln, packet, addr)
 			continue
-
 		}
-		if client, ok := s.UDPConnectionMap[strconv.FormatInt((int64)(addr.Port), 10)+":"+addr.IP.String()]; ok {
-			packet.Client = client
-			s.ParsePacket(packet)
+		s.clientsMu.RLock()
+		client, ok := s.UDPConnectionMap[udpKey(addr)]
+		s.clientsMu.RUnlock()
+		if ok {
+			s.handleUDPPacket(client, addr, packet)
 		} else {
 			log.WithFields(log.Fields{"IP": addr.IP.String(), "Port": addr.Port}).Trace("Got UDP data that doesnt match any client")
 		}
 	}
 }
 
-func (s *GameServer) bindTCP() {
-	log.WithFields(log.Fields{"server_ip": s.IP.String(), "server_port": s.Port}).Debug("Starting TCP server")
-	address := s.IP.String() + ":" + strconv.FormatInt((int64)(s.Port), 10)
+func (s *GameServer) bindTCP(network, address string) {
+	log.WithFields(log.Fields{"network": network, "address": address}).Debug("Starting TCP server")
 
-	ln, err := net.Listen("tcp4", address)
+	ln, err := net.Listen(network, address)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -160,35 +391,36 @@ func (s *GameServer) bindTCP() {
 			log.Trace("Error accepting connection: " + err.Error())
 			continue
 		}
+		if !s.allowConnection(conn.RemoteAddr().String()) {
+			conn.Close()
+			continue
+		}
 		log.WithFields(log.Fields{"IP": conn.RemoteAddr().String()}).Trace("Accepted connection")
 		go s.handleConnection(conn)
 	}
 }
 
 func (s *GameServer) handleConnection(conn net.Conn) {
-	defer conn.Close()
-	buf := make([]byte, 2048)
+	frames := NewFrameReader(conn, s.MaxFrameSize)
 	clientInitialized := false
-	LocalClient := newClient()
-	LocalClient.Conn = &conn
-	LocalClient.IP = conn.RemoteAddr().(*net.TCPAddr).IP.String()
+	stream := newStreamConnection(conn, s.SendQueueSize)
+	LocalClient := newClient(stream)
+	LocalClient.IP = remoteIP(conn.RemoteAddr())
+	go stream.writeLoop(s.WriteTimeout)
+	// the writer sends what is still queued (like a Disconnecting) and closes conn
+	defer stream.Close()
+	done := make(chan struct{})
+	defer close(done)
 	for {
-		clear(buf)
-		_, err := conn.Read(buf)
+		packet, err := frames.ReadPacket()
 		if err != nil {
-			if err.Error() == "EOF" {
+			// net.ErrClosed is the server closing the connection itself, like for a resumed or kicked client
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
 				break
 			}
-			log.Warn("Error receiving from " + conn.RemoteAddr().String() + ": " + err.Error())
+			log.WithFields(log.Fields{"IP": conn.RemoteAddr().String(), "err": err.Error()}).Warn("Error receiving packet")
 			break
 		}
-
-		packet := &Packet{}
-		err = packet.DigestData(&buf)
-		if err != nil {
-			log.WithFields(log.Fields{"IP": conn.RemoteAddr().String(), "err": err.Error()}).Trace("Failed to digest packet")
-			continue
-		}
 		if !clientInitialized {
 			if packet.Header != messages.Hello {
 				LocalClient.RespondError("NO_HELLO", true)
@@ -196,11 +428,7 @@ func (s *GameServer) handleConnection(conn net.Conn) {
 				break
 			}
 
-			var hello_packet_struct struct {
-				Name    string
-				SteamID string
-				Version string
-			}
+			var hello_packet_struct HelloPacket
 
 			err = packet.ReadPayload(&hello_packet_struct)
 			if err != nil {
@@ -209,46 +437,94 @@ func (s *GameServer) handleConnection(conn net.Conn) {
 				break
 			}
 			// packet data is correct
-			if hello_packet_struct.Version != s.GameVersion {
-				LocalClient.RespondError("INVALID_VERSION", true)
-				log.WithFields(log.Fields{"IP": conn.RemoteAddr().String(), "server_version": s.GameVersion, "client_version": hello_packet_struct.Version}).Trace("Rejecting client - invalid version")
+			LocalClient.useFrameLength(hello_packet_struct.ProtocolVersion)
+			if !s.admit(LocalClient, hello_packet_struct.SteamID) {
+				break
+			}
+			if details, err := s.negotiateVersion(LocalClient, &hello_packet_struct); err != nil {
+				LocalClient.RespondErrorDetails("INVALID_VERSION", details, true)
+				log.WithFields(log.Fields{"IP": conn.RemoteAddr().String(), "server_version": s.GameVersion, "client_version": hello_packet_struct.Version, "error": err.Error()}).Trace("Rejecting client - invalid version")
 				break
 			}
 
-			// initializing client
-			pl := s.initializePlayer(hello_packet_struct.Name, LocalClient)
-			pl.SteamID = hello_packet_struct.SteamID
-			log.WithFields(log.Fields{"Name": pl.Name, "ID": pl.ID}).Debug("Client initialized")
-			respPacket := Packet{}
-			respPacket.Header = messages.Data
-			respPacket.Flag = messages.Response.IDAssign
-			var respContent struct {
-				ID int32
+			crypto, serverKey, err := s.negotiate(LocalClient, &hello_packet_struct)
+			if err != nil {
+				reason := "INVALID_HELLO"
+				if errors.Is(err, errEncryptionRequired) {
+					reason = "ENCRYPTION_REQUIRED"
+				}
+				LocalClient.RespondError(reason, true)
+				log.WithFields(log.Fields{"IP": conn.RemoteAddr().String(), "error": err.Error()}).Trace("Rejecting client - handshake failed")
+				break
+			}
+
+			var pl *Player
+			resumed := false
+			if old := s.resume(hello_packet_struct.ResumeToken); old != nil {
+				s.takeOver(old, LocalClient)
+				if old.replaced {
+					old.Disconnect("SESSION_RESUMED")
+				}
+				pl = LocalClient.ConnectedPlayer
+				resumed = true
+				log.WithFields(log.Fields{"Name": pl.Name, "ID": pl.ID}).Debug("Client resumed")
+			} else {
+				// initializing client
+				pl = s.initializePlayer(hello_packet_struct.Name, LocalClient)
+				pl.SteamID = hello_packet_struct.SteamID
+				log.WithFields(log.Fields{"Name": pl.Name, "ID": pl.ID}).Debug("Client initialized")
 			}
+			if s.ResumeGrace > 0 {
+				// a new token every time, an old one can't be used twice
+				if LocalClient.resumeToken, err = newResumeToken(); err != nil {
+					log.WithField("error", err.Error()).Error("Failed to create resume token")
+				}
+			}
+			if LocalClient.udpSecret, err = newUDPSecret(); err != nil {
+				log.WithField("error", err.Error()).Error("Failed to create UDP secret")
+			}
+			respPacket := LocalClient.NewPacket(messages.Data, messages.Response.IDAssign)
+			var respContent IDAssignPacket
 			respContent.ID = pl.ID
+			respContent.ResumeToken = LocalClient.resumeToken
+			respContent.Resumed = resumed
+			respContent.UDPSecret = LocalClient.udpSecret
+			respContent.Capabilities = uint32(LocalClient.Capabilities)
+			respContent.PublicKey = serverKey
+			respContent.ProtocolVersion = LocalClient.ProtocolVersion
 			if err := respPacket.AddToPayload(&respContent); err != nil {
 				log.Error(err.Error())
 			}
-			respPacket.Send(conn)
+			if err := LocalClient.Send(&respPacket); err != nil {
+				log.WithFields(log.Fields{"IP": conn.RemoteAddr().String(), "error": err.Error()}).Debug("Failed to send IDAssign")
+				break
+			}
+			s.enableCapabilities(LocalClient, crypto)
 			LocalClient.ConnectedPlayer = pl
 			clientInitialized = true
+			if LocalClient.ProtocolVersion >= HeartbeatVersion {
+				go s.heartbeatLoop(LocalClient, done)
+			}
+			if resumed && pl.Lobby != nil {
+				pl.Lobby.BroadcastInfo()
+			}
 			continue
 		}
+		if err := s.openFrame(LocalClient, packet, false); err != nil {
+			log.WithFields(log.Fields{"IP": conn.RemoteAddr().String(), "err": err.Error()}).Debug("Dropping frame")
+			continue
+		}
+		LocalClient.heartbeat.alive()
 		packet.Client = LocalClient
 		s.ParsePacket(packet)
 	}
 	if LocalClient.ConnectedPlayer == nil {
 		return
 	}
-	log.WithFields(log.Fields{"name": LocalClient.ConnectedPlayer.Name, "id": LocalClient.ConnectedPlayer.ID}).Trace("Player disconnected")
-	delete(s.UDPConnectionMap, strconv.FormatInt((int64)(LocalClient.ConnectedPlayer.NetworkClient.UDPPort), 10)+":"+LocalClient.IP)
-	if LocalClient.ConnectedPlayer.Lobby != nil {
-		LocalClient.ConnectedPlayer.Lobby.RemovePlayer(LocalClient.ConnectedPlayer)
+	if s.suspend(LocalClient) {
+		return
 	}
-
-	s.Clients = slices.DeleteFunc(s.Clients, func(n *Client) bool {
-		return n == LocalClient
-	})
+	s.removeClient(LocalClient)
 }
 
 func (s *GameServer) initializePlayer(name string, client *Client) *Player {
@@ -257,6 +533,8 @@ func (s *GameServer) initializePlayer(name string, client *Client) *Player {
 	NewPlayer.IP = client.IP
 	NewPlayer.NetworkClient = client
 	client.ConnectedPlayer = NewPlayer
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
 	idValid := true
 	for {
 
@@ -275,3 +553,10 @@ func (s *GameServer) initializePlayer(name string, client *Client) *Player {
 	s.Clients = append(s.Clients, client)
 	return NewPlayer
 }
+
+// clientList is a copy of Clients to go through without holding clientsMu
+func (s *GameServer) clientList() []*Client {
+	s.clientsMu.RLock()
+	defer s.clientsMu.RUnlock()
+	return slices.Clone(s.Clients)
+}
@@ -1,6 +1,7 @@
 package GameServer
 
 import (
+	"sync"
 	"time"
 
 	"MonophobiaServer/messages"
@@ -64,18 +65,24 @@ type PlayerData struct {
 type Lobby struct {
 	Owner             *Player
 	Name              string
-	Players           []*Player
+	Players           []*Player // guarded by playersMu, the lobby goroutine reads it while clients join and leave
+	playersMu         sync.RWMutex
 	Map               string
 	MapSeed           int32
 	MaxPlayers        int32
 	PasswordProtected bool
 	Password          string
 	ID                int32
+	JoinCode          string    // short code players can share to join, see newJoinCode
+	Created           time.Time // for sorting the lobby list by age
 	Started           bool
 	WorldState        WorldState
 	LogicChannel      chan Packet
 	MessageChannel    chan LobbyMessage
 	TickRate          time.Duration
+	HostMigration     HostMigration     // how the next owner is picked when the owner leaves
+	Settings          map[string]string // custom game settings set by the owner
+	Bans              []LobbyBan        // players the owner banned, kept until the lobby closes
 }
 
 func (l *Lobby) ToNetwork() *NetworkLobbyInfo {
@@ -83,8 +90,13 @@ func (l *Lobby) ToNetwork() *NetworkLobbyInfo {
 	inf.LobbyName = l.Name
 	inf.MapName = l.Map
 	inf.Time = 0
-	inf.Players = make([]NetworkPlayerInfo, len(l.Players))
-	for i, pl := range l.Players {
+	inf.MaxPlayers = l.MaxPlayers
+	inf.PasswordProtected = l.PasswordProtected
+	inf.Settings = l.Settings
+	inf.JoinCode = l.JoinCode
+	players := l.playerList()
+	inf.Players = make([]NetworkPlayerInfo, len(players))
+	for i, pl := range players {
 		inf.Players[i] = *pl.ToNetwork()
 	}
 	return inf
@@ -95,6 +107,11 @@ type NetworkLobbyInfo struct {
 	MapName   string
 	Time      int32
 	Players   []NetworkPlayerInfo
+	// the rest of the settings the owner can change with UpdateLobbyInfo
+	MaxPlayers        int32             `mono:"since=3"`
+	PasswordProtected bool              `mono:"since=3"`
+	Settings          map[string]string `mono:"since=3,max=32"`
+	JoinCode          string            `mono:"since=4,max=16"`
 }
 
 type Player struct {
@@ -111,6 +128,7 @@ type Player struct {
 	NetworkClient    *Client
 	FutureTransforms Transforms
 	Transforms       Transforms
+	Reconnecting     bool // connection dropped, the seat is kept for GameServer.ResumeGrace
 }
 
 func (pl *Player) ToNetwork() *NetworkPlayerInfo {
@@ -120,16 +138,18 @@ func (pl *Player) ToNetwork() *NetworkPlayerInfo {
 	newData.Cosmetics = pl.Cosmetics
 	newData.IsHost = pl.IsHost
 	newData.IsMonster = pl.IsMonster
+	newData.Reconnecting = pl.Reconnecting
 	return newData
 }
 
 type NetworkPlayerInfo struct {
-	ID        int32
-	Name      string
-	Cosmetics []string
-	Skin      string
-	IsMonster bool
-	IsHost    bool
+	ID           int32
+	Name         string
+	Cosmetics    []string
+	Skin         string
+	IsMonster    bool
+	IsHost       bool
+	Reconnecting bool `mono:"since=2"`
 }
 
 type Packet struct {
@@ -137,6 +157,8 @@ type Packet struct {
 	Header         messages.Header
 	Flag           messages.Flag
 	FullMsgLen     int32
+	Version        int32 // protocol version of the payload, 0 means ProtocolVersion
 	Payload        []byte
 	payloadPointer int32
+	frameFlags     uint32 // FrameCompressed/FrameEncrypted bits that still apply to Payload
 }
@@ -0,0 +1,169 @@
+package GameServer
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"MonophobiaServer/messages"
+)
+
+// Connection is how packets get to a client. The game logic only talks to clients through it,
+// so it doesn't care whether they are behind TCP, a WebSocket, UDP or in the same process (tests, bots).
+type Connection interface {
+	// Send delivers the packet reliably and in order
+	Send(packet *Packet) error
+	// SendUnreliable may drop the packet, e.g. when the client falls behind
+	SendUnreliable(packet *Packet) error
+	Close() error
+	RemoteAddr() net.Addr
+}
+
+// lastWordCloser is implemented by connections that can drop what is queued and close after one last packet, see Client.Disconnect
+type lastWordCloser interface {
+	closeWith(last *Packet)
+}
+
+// sessionConnection is implemented by connections that frame, compress and encrypt, see GameServer.enableCapabilities
+type sessionConnection interface {
+	useSession(compressThreshold int, crypto *session)
+	useFullFrameLength(full bool)
+}
+
+// udpConnection is the bound UDP endpoint of a client seen as a Connection.
+// Reliable packets go on the ReliableOrdered channel, unreliable ones as plain datagrams.
+type udpConnection struct {
+	server *GameServer
+	client *Client
+}
+
+// UDPConnection returns the client's UDP endpoint as a Connection, sending fails until the client bound one
+func (s *GameServer) UDPConnection(c *Client) Connection {
+	return &udpConnection{s, c}
+}
+
+func (u *udpConnection) Send(packet *Packet) error {
+	if u.client.UDPAddr == nil || u.client.udp == nil {
+		return fmt.Errorf("client has no UDP address bound")
+	}
+	return u.server.SendChannel(u.client, packet, messages.Channel.ReliableOrdered)
+}
+
+func (u *udpConnection) SendUnreliable(packet *Packet) error {
+	return u.server.SendUDP(u.client, packet)
+}
+
+// Close unbinds the endpoint, the client has to bind again to get UDP traffic
+func (u *udpConnection) Close() error {
+	u.server.clientsMu.Lock()
+	defer u.server.clientsMu.Unlock()
+	if u.client.UDPAddr == nil {
+		return nil
+	}
+	delete(u.server.UDPConnectionMap, udpKey(u.client.UDPAddr))
+	u.server.reassembler.Forget(u.client.UDPAddr.String())
+	u.server.unregisterEndpoint(u.client)
+	u.client.UDPPort = -1
+	u.client.UDPAddr = nil
+	u.client.udpConn = nil
+	return nil
+}
+
+func (u *udpConnection) RemoteAddr() net.Addr {
+	if u.client.UDPAddr == nil {
+		return nil
+	}
+	return u.client.UDPAddr
+}
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// PipeConnection is an in-memory Connection. Whatever the server sends is read with Receive, nothing is encoded on the way
+// except the payload, so tests and bots read it with Packet.ReadPayload like a client would.
+type PipeConnection struct {
+	name    string
+	packets chan Packet
+	mu      sync.Mutex
+	closed  bool
+}
+
+// NewPipeConnection makes a pipe that holds up to buffer packets the other end didn't receive yet
+func NewPipeConnection(name string, buffer int) *PipeConnection {
+	if buffer <= 0 {
+		buffer = DefaultSendQueueSize
+	}
+	return &PipeConnection{name: name, packets: make(chan Packet, buffer)}
+}
+
+func (p *PipeConnection) push(packet *Packet, full error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrClientClosed
+	}
+	pac := *packet
+	pac.Payload = append([]byte(nil), packet.Payload...)
+	select {
+	case p.packets <- pac:
+		return nil
+	default:
+		return full
+	}
+}
+
+// Send never blocks, a reader that doesn't keep up gets disconnected like a TCP client would
+func (p *PipeConnection) Send(packet *Packet) error {
+	return p.push(packet, errSendQueueStalled)
+}
+
+func (p *PipeConnection) SendUnreliable(packet *Packet) error {
+	return p.push(packet, ErrSendQueueFull)
+}
+
+func (p *PipeConnection) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.packets)
+	}
+	return nil
+}
+
+func (p *PipeConnection) RemoteAddr() net.Addr {
+	return pipeAddr(p.name)
+}
+
+// Receive returns the next packet sent to the pipe, false once it is closed and empty
+func (p *PipeConnection) Receive() (Packet, bool) {
+	pac, ok := <-p.packets
+	return pac, ok
+}
+
+// Packets is the channel Receive reads from, for selecting on it
+func (p *PipeConnection) Packets() <-chan Packet {
+	return p.packets
+}
+
+// ConnectLocal adds a client on conn as if it had completed the Hello handshake, for tests and bots running in-process.
+// Its packets are handed to ParsePacket by the caller, with Packet.Client set to the returned client.
+func (s *GameServer) ConnectLocal(conn Connection, name string) *Client {
+	c := newClient(conn)
+	c.IP = conn.RemoteAddr().String()
+	c.ProtocolVersion = ProtocolVersion
+	c.GameVersion = s.GameVersion
+	c.useFrameLength(ProtocolVersion)
+	s.initializePlayer(name, c)
+	return c
+}
+
+// DisconnectLocal removes a client added with ConnectLocal, like handleConnection does when a connection ends
+func (s *GameServer) DisconnectLocal(c *Client) {
+	c.Conn.Close()
+	if c.ConnectedPlayer != nil {
+		s.removeClient(c)
+	}
+}
@@ -0,0 +1,193 @@
+package GameServer
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"MonophobiaServer/messages"
+)
+
+func newTestServer(t *testing.T) *GameServer {
+	t.Helper()
+	s := &GameServer{GameVersion: "1.0.0"}
+	if err := s.initState(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func writeFrame(t *testing.T, conn net.Conn, header messages.Header, flag messages.Flag, payload any) {
+	t.Helper()
+	p := &Packet{Header: header, Flag: flag, Version: ProtocolVersion}
+	if err := p.AddToPayload(payload); err != nil {
+		t.Fatal(err)
+	}
+	frame, err := p.assembleMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readUntil skips frames, like heartbeats, until one with header and flag arrives
+func readUntil(t *testing.T, frames *FrameReader, header messages.Header, flag messages.Flag) *Packet {
+	t.Helper()
+	for {
+		p, err := frames.ReadPacket()
+		if err != nil {
+			t.Fatalf("waiting for %v/%d: %v", header, flag, err)
+		}
+		if p.Header == header && p.Flag == flag {
+			p.Version = ProtocolVersion
+			return p
+		}
+	}
+}
+
+func receiveUntil(t *testing.T, conn *PipeConnection, flag messages.Flag) *Packet {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case p, ok := <-conn.Packets():
+			if !ok {
+				t.Fatalf("pipe closed waiting for flag %d", flag)
+			}
+			if p.Header == messages.Data && p.Flag == flag {
+				return &p
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for flag %d", flag)
+		}
+	}
+}
+
+// lobbyInfoWith skips LobbyInfo updates until one with the given number of players arrives
+func lobbyInfoWith(t *testing.T, conn *PipeConnection, players int) NetworkLobbyInfo {
+	t.Helper()
+	for {
+		var info NetworkLobbyInfo
+		if err := receiveUntil(t, conn, messages.Response.LobbyInfo).ReadPayload(&info); err != nil {
+			t.Fatal(err)
+		}
+		if len(info.Players) == players {
+			return info
+		}
+	}
+}
+
+func TestHandshakeAndCreateLobby(t *testing.T) {
+	s := newTestServer(t)
+	conn, server := net.Pipe()
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	go s.handleConnection(server)
+	frames := NewFrameReader(conn, 0)
+
+	writeFrame(t, conn, messages.Hello, messages.None, &HelloPacket{Name: "Zażółć", SteamID: "1", Version: s.GameVersion, ProtocolVersion: ProtocolVersion})
+	var id IDAssignPacket
+	if err := readUntil(t, frames, messages.Data, messages.Response.IDAssign).ReadPayload(&id); err != nil {
+		t.Fatal(err)
+	}
+	if id.ProtocolVersion != ProtocolVersion || len(id.UDPSecret) == 0 {
+		t.Fatalf("unexpected IDAssign %+v", id)
+	}
+
+	writeFrame(t, conn, messages.Data, messages.Post.CreateLobby, &CreateLobbyPacket{Name: "Test lobby", MaxPlayers: 4})
+	var info NetworkLobbyInfo
+	if err := readUntil(t, frames, messages.Data, messages.Response.LobbyInfo).ReadPayload(&info); err != nil {
+		t.Fatal(err)
+	}
+	if info.LobbyName != "Test lobby" || info.MaxPlayers != 4 || len(info.JoinCode) != joinCodeLength {
+		t.Fatalf("unexpected lobby %+v", info)
+	}
+	if len(info.Players) != 1 || info.Players[0].ID != id.ID || info.Players[0].Name != "Zażółć" || !info.Players[0].IsHost {
+		t.Fatalf("unexpected players %+v", info.Players)
+	}
+}
+
+func TestPipeLobby(t *testing.T) {
+	s := newTestServer(t)
+	ownerConn, guestConn, browserConn := NewPipeConnection("owner", 64), NewPipeConnection("guest", 64), NewPipeConnection("browser", 64)
+	owner, guest := s.ConnectLocal(ownerConn, "owner"), s.ConnectLocal(guestConn, "guest")
+	s.ConnectLocal(browserConn, "browser")
+	send := func(c *Client, flag messages.Flag, payload any) {
+		p := c.NewPacket(messages.Data, flag)
+		if err := p.AddToPayload(payload); err != nil {
+			t.Fatal(err)
+		}
+		p.Client = c
+		s.ParsePacket(&p)
+	}
+
+	send(owner, messages.Post.CreateLobby, &CreateLobbyPacket{Name: "pipes", MaxPlayers: 3})
+	receiveUntil(t, browserConn, messages.Response.LobbyListChanged)
+	var list LobbyListPacket
+	send(guest, messages.Request.LobbyList, &LobbyListRequestPacket{})
+	if err := receiveUntil(t, guestConn, messages.Response.LobbyList).ReadPayload(&list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 1 || list.Lobbies[0].Name != "pipes" {
+		t.Fatalf("unexpected lobby list %+v", list)
+	}
+
+	send(guest, messages.Post.JoinLobby, &JoinLobbyPacket{LobbyID: list.Lobbies[0].ID})
+	info := lobbyInfoWith(t, ownerConn, 2)
+	if info.Players[1].ID != guest.ConnectedPlayer.ID {
+		t.Fatalf("owner saw %+v", info.Players)
+	}
+	// one lobby at a time
+	send(guest, messages.Post.CreateLobby, &CreateLobbyPacket{Name: "second", MaxPlayers: 3})
+	if len(s.Lobbies) != 1 || guest.ConnectedPlayer.Lobby.Name != "pipes" {
+		t.Fatalf("guest in a lobby created lobby %q, %d lobbies", guest.ConnectedPlayer.Lobby.Name, len(s.Lobbies))
+	}
+
+	// the guest takes over when the owner leaves
+	s.DisconnectLocal(owner)
+	info = lobbyInfoWith(t, guestConn, 1)
+	if info.Players[0].ID != guest.ConnectedPlayer.ID || !info.Players[0].IsHost {
+		t.Fatalf("guest saw %+v after the owner left", info.Players)
+	}
+}
+
+// readLegacyFrame reads a frame whose length field counts only the payload, as clients before FrameLengthVersion get them
+func readLegacyFrame(t *testing.T, conn net.Conn) *Packet {
+	t.Helper()
+	header := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Fatal(err)
+	}
+	length, _ := splitLength(binary.LittleEndian.Uint32(header[3:7]))
+	p := &Packet{Header: messages.Header(binary.BigEndian.Uint16(header[:2])), Flag: messages.Flag(header[2]), Payload: make([]byte, length), Version: 1}
+	if _, err := io.ReadFull(conn, p.Payload); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// TestLegacyEcho checks that clients from before heartbeats aren't timed out and get the echo they expect
+func TestLegacyEcho(t *testing.T) {
+	s := newTestServer(t)
+	s.HeartbeatInterval = 10 * time.Millisecond
+	s.MaxMissedHeartbeats = 2
+	conn, server := net.Pipe()
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	go s.handleConnection(server)
+
+	writeFrame(t, conn, messages.Hello, messages.None, &HelloPacket{Name: "old", SteamID: "1", Version: s.GameVersion})
+	if p := readLegacyFrame(t, conn); p.Flag != messages.Response.IDAssign {
+		t.Fatalf("got %v/%d, want IDAssign", p.Header, p.Flag)
+	}
+	// long enough to be timed out if it was pinged
+	time.Sleep(10 * s.HeartbeatInterval)
+	writeFrame(t, conn, messages.Echo, messages.None, &HeartbeatPacket{Sequence: 7})
+	if p := readLegacyFrame(t, conn); p.Header != messages.Echo || p.Flag != messages.None || len(p.Payload) != 0 {
+		t.Fatalf("got %v/%d with %d payload bytes, want an empty Echo/None", p.Header, p.Flag, len(p.Payload))
+	}
+}
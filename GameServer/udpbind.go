@@ -0,0 +1,162 @@
+package GameServer
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"time"
+
+	"MonophobiaServer/messages"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	udpSecretSize = 32
+	// bindCookieLifetime is how long a client has to answer a bind challenge
+	bindCookieLifetime = 10 * time.Second
+	bindCookieMACSize  = 16
+	bindCookieSize     = 8 + bindCookieMACSize
+)
+
+func newUDPSecret() ([]byte, error) {
+	secret := make([]byte, udpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// bindCookie is the challenge for binding addr to a player. It is a MAC under a key only the server knows,
+// so nothing has to be stored per challenge and a flood of bind requests can't push out the challenge of the real client.
+func (s *GameServer) bindCookie(id int32, addr *net.UDPAddr, issued time.Time) []byte {
+	cookie := binary.LittleEndian.AppendUint64(nil, uint64(issued.Unix()))
+	mac := hmac.New(sha256.New, s.bindKey)
+	mac.Write(cookie)
+	mac.Write(binary.LittleEndian.AppendUint32(nil, uint32(id)))
+	mac.Write([]byte(addr.String()))
+	return mac.Sum(cookie)[:bindCookieSize]
+}
+
+func (s *GameServer) checkBindCookie(id int32, addr *net.UDPAddr, cookie []byte, now time.Time) bool {
+	if len(cookie) != bindCookieSize {
+		return false
+	}
+	issued := time.Unix(int64(binary.LittleEndian.Uint64(cookie)), 0)
+	// the cookie only has whole seconds
+	if now.Sub(issued) > bindCookieLifetime || issued.After(now) {
+		return false
+	}
+	return hmac.Equal(cookie, s.bindCookie(id, addr, issued))
+}
+
+func bindMAC(secret, cookie []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(cookie)
+	return mac.Sum(nil)
+}
+
+func (s *GameServer) clientByID(id int32) *Client {
+	s.clientsMu.RLock()
+	defer s.clientsMu.RUnlock()
+	for _, c := range s.Clients {
+		if c.ConnectedPlayer != nil && c.ConnectedPlayer.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func bindFailed(addr *net.UDPAddr, id int32, reason string) {
+	log.WithFields(log.Fields{"IP": addr.String(), "PlayerID": id, "reason": reason}).Warn("UDP bind failed")
+}
+
+// handleBind runs the server side of the ImHere handshake. Player IDs are public, so an ImHere alone proves nothing:
+// the client has to answer a challenge sent to the address it claims with a MAC keyed by the secret from its IDAssign.
+func (s *GameServer) handleBind(conn *net.UDPConn, packet *Packet, addr *net.UDPAddr) {
+	switch packet.Flag {
+	case messages.Bind.Request:
+		var req ImHerePacket
+		if err := packet.ReadPayload(&req); err != nil {
+			bindFailed(addr, -1, "invalid request: "+err.Error())
+			return
+		}
+		// only answer for connected players, so the challenge can't be used to bounce traffic at any address
+		client := s.clientByID(req.ID)
+		if client == nil || client.udpSecret == nil {
+			bindFailed(addr, req.ID, "unknown player")
+			return
+		}
+		challenge := Packet{Header: messages.ImHere, Flag: messages.Bind.Challenge}
+		if err := challenge.AddToPayload(&BindChallengePacket{Cookie: s.bindCookie(req.ID, addr, time.Now())}); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to add bind challenge to packet")
+			return
+		}
+		if err := challenge.SendUDPTo(conn, addr); err != nil {
+			log.WithFields(log.Fields{"IP": addr.String(), "error": err.Error()}).Debug("Failed to send bind challenge")
+		}
+	case messages.Bind.Response:
+		var resp BindResponsePacket
+		if err := packet.ReadPayload(&resp); err != nil {
+			bindFailed(addr, -1, "invalid response: "+err.Error())
+			return
+		}
+		if !s.checkBindCookie(resp.ID, addr, resp.Cookie, time.Now()) {
+			bindFailed(addr, resp.ID, "invalid or expired cookie")
+			return
+		}
+		client := s.clientByID(resp.ID)
+		if client == nil || client.udpSecret == nil {
+			bindFailed(addr, resp.ID, "unknown player")
+			return
+		}
+		if !hmac.Equal(resp.MAC, bindMAC(client.udpSecret, resp.Cookie)) {
+			bindFailed(addr, resp.ID, "wrong MAC")
+			return
+		}
+		s.bindEndpoint(client, conn, addr)
+		bound := Packet{Header: messages.ImHere, Flag: messages.Bind.Bound}
+		if err := bound.AddToPayload(&ImHerePacket{ID: resp.ID}); err != nil {
+			log.WithField("error", err.Error()).Error("Failed to add bind confirmation to packet")
+			return
+		}
+		if err := bound.SendUDPTo(conn, addr); err != nil {
+			log.WithFields(log.Fields{"IP": addr.String(), "error": err.Error()}).Debug("Failed to send bind confirmation")
+		}
+	default:
+		bindFailed(addr, -1, "unknown flag "+strconv.Itoa(int(packet.Flag)))
+	}
+}
+
+// bindEndpoint points the client's UDP traffic at addr. A client that is already bound is moved over,
+// keeping its channel state, which is what happens when its NAT hands out a new port.
+func (s *GameServer) bindEndpoint(client *Client, conn *net.UDPConn, addr *net.UDPAddr) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	key := udpKey(addr)
+	if other, ok := s.UDPConnectionMap[key]; ok && other != client {
+		// the address was reused, whoever had it can't be reached there anymore
+		other.UDPPort = -1
+		other.UDPAddr = nil
+		other.udpConn = nil
+		s.unregisterEndpoint(other)
+	}
+	if client.UDPAddr != nil {
+		if udpKey(client.UDPAddr) == key && client.udpConn == conn {
+			return
+		}
+		delete(s.UDPConnectionMap, udpKey(client.UDPAddr))
+		s.reassembler.Forget(client.UDPAddr.String())
+		log.WithFields(log.Fields{"PlayerID": client.ConnectedPlayer.ID, "Old": client.UDPAddr.String(), "New": addr.String()}).Debug("Player rebound UDP endpoint")
+	} else {
+		s.registerEndpoint(client)
+		log.WithFields(log.Fields{"PlayerID": client.ConnectedPlayer.ID, "New_UDP_Port": addr.Port}).Trace("Player initialized UDP port")
+	}
+	client.UDPPort = addr.Port
+	client.UDPAddr = addr
+	client.udpConn = conn
+	s.UDPConnectionMap[key] = client
+}
@@ -0,0 +1,249 @@
+package GameServer
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WebSocket transport (RFC 6455) for clients that can't open raw sockets, like WebGL builds.
+// Every binary message carries frames exactly as they are sent over TCP, the connection is then handled like a TCP one.
+// These clients never bind UDP, so everything that would go over UDP falls back to their TCP queue.
+
+const (
+	webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// WebSocketSubprotocol is echoed back if the client offers it, clients don't have to
+	WebSocketSubprotocol = "monophobia"
+
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsCloseNormal      = 1000
+	wsCloseProtocol    = 1002
+	wsCloseUnsupported = 1003
+	wsCloseTooBig      = 1009
+
+	// wsMaxControlPayload is the limit the RFC puts on control frames
+	wsMaxControlPayload = 125
+)
+
+var errWebSocketClosed = errors.New("websocket closed")
+
+// wsConn turns a WebSocket into the byte stream handleConnection reads frames from.
+// Each Write is sent as one binary message, the writer only ever writes whole frames.
+type wsConn struct {
+	net.Conn
+	r        *bufio.Reader
+	maxSize  int64
+	writeMu  sync.Mutex
+	pending  []byte // rest of the current message
+	inMsg    bool   // a fragmented message is being read
+	closeErr error
+}
+
+func wsAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ServeHTTP upgrades the request to a WebSocket and handles it like any other client connection
+func (s *GameServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected a websocket upgrade", http.StatusBadRequest)
+		return
+	}
+	if !s.allowConnection(r.RemoteAddr) {
+		http.Error(w, "too many connections", http.StatusTooManyRequests)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		log.WithField("error", err.Error()).Debug("Failed to hijack websocket connection")
+		return
+	}
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + wsAccept(key) + "\r\n"
+	if headerContains(r.Header, "Sec-WebSocket-Protocol", WebSocketSubprotocol) {
+		resp += "Sec-WebSocket-Protocol: " + WebSocketSubprotocol + "\r\n"
+	}
+	if _, err := conn.Write([]byte(resp + "\r\n")); err != nil {
+		conn.Close()
+		return
+	}
+	log.WithFields(log.Fields{"IP": conn.RemoteAddr().String()}).Trace("Accepted websocket connection")
+	s.handleConnection(&wsConn{Conn: conn, r: rw.Reader, maxSize: int64(s.maxFrameSize())})
+}
+
+// bindWebSocket serves the WebSocket listener on address, in front of the same lobbies as the TCP listener
+func (s *GameServer) bindWebSocket(address string) {
+	log.WithFields(log.Fields{"address": address}).Debug("Starting WebSocket server")
+	if err := http.ListenAndServe(address, s); err != nil {
+		log.WithField("error", err).Fatal("Failed to serve WebSocket")
+	}
+}
+
+// readFrame reads one WebSocket frame. Client frames have to be masked.
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.r, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0F
+	if head[0]&0x70 != 0 {
+		return fin, opcode, nil, c.fail(wsCloseProtocol, "reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return fin, opcode, nil, c.fail(wsCloseProtocol, "unmasked client frame")
+	}
+	length := int64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if opcode >= wsOpClose && (length > wsMaxControlPayload || !fin) {
+		return fin, opcode, nil, c.fail(wsCloseProtocol, "invalid control frame")
+	}
+	if length < 0 || length > c.maxSize {
+		return fin, opcode, nil, c.fail(wsCloseTooBig, "message too big")
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.r, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.r, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// Read hands out the payload of binary messages as one stream, answering control frames on the way
+func (c *wsConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		if c.closeErr != nil {
+			return 0, c.closeErr
+		}
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, err
+		}
+		switch opcode {
+		case wsOpBinary, wsOpContinuation:
+			if (opcode == wsOpContinuation) != c.inMsg {
+				return 0, c.fail(wsCloseProtocol, "unexpected continuation")
+			}
+			c.inMsg = !fin
+			c.pending = payload
+		case wsOpText:
+			return 0, c.fail(wsCloseUnsupported, "text messages are not supported")
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return 0, err
+			}
+		case wsOpPong:
+		case wsOpClose:
+			// echo the status code back, that completes the closing handshake
+			if len(payload) >= 2 {
+				payload = payload[:2]
+			}
+			c.writeFrame(wsOpClose, payload)
+			c.closeErr = io.EOF
+		default:
+			return 0, c.fail(wsCloseProtocol, fmt.Sprintf("unknown opcode %d", opcode))
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = binary.BigEndian.AppendUint16(append(frame, 126), uint16(len(payload)))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, 127), uint64(len(payload)))
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.Conn.Write(append(frame, payload...))
+	return err
+}
+
+// Write sends p as one binary message
+func (c *wsConn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(wsOpBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// fail sends a close frame with the status code and returns the error that ends reading
+func (c *wsConn) fail(code uint16, reason string) error {
+	c.writeFrame(wsOpClose, append(binary.BigEndian.AppendUint16(nil, code), reason...))
+	c.closeErr = fmt.Errorf("%w : %s", errWebSocketClosed, reason)
+	return c.closeErr
+}
+
+func (c *wsConn) Close() error {
+	c.writeFrame(wsOpClose, binary.BigEndian.AppendUint16(nil, wsCloseNormal))
+	return c.Conn.Close()
+}
@@ -4,21 +4,47 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"MonophobiaServer/GameServer"
 
 	log "github.com/sirupsen/logrus"
 )
 
-var FlagLogLevel, FlagIP string
-var FlagPort int
+var FlagLogLevel, FlagIP, FlagEncryption, FlagVersions, FlagWebSocket string
+var FlagRateLimits, FlagIPRateLimits, FlagConnectRate, FlagAccessList, FlagHostMigration string
+var FlagPort, FlagMaxFrameSize, FlagUDPMTU, FlagCompressThreshold, FlagSendQueue, FlagMaxMissed int
+var FlagWriteTimeout, FlagHeartbeat, FlagResumeGrace time.Duration
 
 func init() {
 	flag.StringVar(&FlagLogLevel, "log", "info", "Set log level ( none, info, error, debug )")
-	flag.StringVar(&FlagIP, "ip", "0.0.0.0", "Set IP for the server")
+	flag.StringVar(&FlagIP, "ip", "", "Set comma separated IPs for the server to listen on, empty listens on every IPv4 and IPv6 address")
 	flag.IntVar(&FlagPort, "port", 1338, "Set Port for the server")
+	flag.IntVar(&FlagUDPMTU, "udp-mtu", GameServer.DefaultUDPMTU, "Set largest UDP datagram the server sends, bigger packets get fragmented")
+	flag.IntVar(&FlagMaxFrameSize, "max-frame", GameServer.DefaultMaxFrameSize, "Set maximum size of a single packet in bytes")
+	flag.IntVar(&FlagSendQueue, "send-queue", GameServer.DefaultSendQueueSize, "Set how many packets may wait to be sent to one client before it is dropped")
+	flag.DurationVar(&FlagWriteTimeout, "write-timeout", GameServer.DefaultWriteTimeout, "Set how long a write to a client may block before it is dropped")
+	flag.DurationVar(&FlagHeartbeat, "heartbeat", GameServer.DefaultHeartbeatInterval, "Set how often clients are pinged")
+	flag.IntVar(&FlagMaxMissed, "max-missed", GameServer.DefaultMaxMissedHeartbeats, "Set how many heartbeats a client may miss before it is disconnected")
+	flag.DurationVar(&FlagResumeGrace, "resume-grace", GameServer.DefaultResumeGrace, "Set how long a player whose connection dropped keeps its seat, 0 disables resuming")
+	flag.StringVar(&FlagVersions, "versions", "", "Set range of client versions to accept, e.g. \">=0.1.0 <0.3.0\" or \"^0.1.1\", empty accepts only the server version")
+	flag.StringVar(&FlagWebSocket, "ws", "", "Set host:port to serve WebSocket clients on, empty disables WebSocket")
+	flag.StringVar(&FlagRateLimits, "rate-limits", "", "Override per client rate limits, comma separated CLASS=RATE/BURST ( create_lobby, lobby_list, join_lobby, gameplay, ack, transport, control )")
+	flag.StringVar(&FlagIPRateLimits, "ip-rate-limits", "", "Override per IP rate limits, same format as -rate-limits")
+	flag.StringVar(&FlagConnectRate, "connect-rate", GameServer.DefaultConnectRate.String(), "Set new connections per second and burst allowed per IP, as RATE/BURST")
+	flag.StringVar(&FlagAccessList, "access-list", "", "Set path of the JSON ban and allow list, reloaded when it changes")
+	flag.StringVar(&FlagHostMigration, "host-migration", "longest", "Set how a lobby picks its next owner when the owner leaves ( longest, rtt )")
+	flag.StringVar(&FlagEncryption, "encryption", "off", "Set whether client sessions are encrypted ( off, optional, required )")
+	flag.IntVar(&FlagCompressThreshold, "compress-threshold", GameServer.DefaultCompressionThreshold, "Set payload size from which packets get compressed for clients that support it, 0 disables compression")
 }
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		if err := runSchema(os.Args[2:]); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
 
 	flag.Parse()
 
@@ -37,9 +63,50 @@ func main() {
 	}
 
 	var server GameServer.GameServer = GameServer.GameServer{} //{IP: FlagIP, Port: int64(FlagPort)}
-	server.SetAddress(FlagIP, FlagPort)
+	if err := server.SetAddress(FlagIP, FlagPort); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
 	server.GameVersion = "0.1.1"
-	log.WithFields(log.Fields{"IP": server.IP.String(), "Port": FlagPort}).Info("Staring server...")
+	server.WebSocketAddress = FlagWebSocket
+	server.AccessListPath = FlagAccessList
+	if FlagVersions != "" {
+		server.Versions, err = GameServer.ParseVersionRange(FlagVersions)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+	}
+	server.MaxFrameSize = int32(FlagMaxFrameSize)
+	server.UDPMTU = FlagUDPMTU
+	server.CompressionThreshold = FlagCompressThreshold
+	server.SendQueueSize = FlagSendQueue
+	server.WriteTimeout = FlagWriteTimeout
+	server.HeartbeatInterval = FlagHeartbeat
+	server.MaxMissedHeartbeats = FlagMaxMissed
+	server.ResumeGrace = FlagResumeGrace
+	if server.RateLimits, err = GameServer.ParseRateLimits(FlagRateLimits, GameServer.DefaultRateLimits); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	if server.IPRateLimits, err = GameServer.ParseRateLimits(FlagIPRateLimits, GameServer.DefaultIPRateLimits); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	if server.ConnectRate, err = GameServer.ParseRateLimit(FlagConnectRate); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	if server.HostMigration, err = GameServer.ParseHostMigration(FlagHostMigration); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	server.Encryption, err = GameServer.ParseEncryptionMode(FlagEncryption)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	log.WithFields(log.Fields{"IP": FlagIP, "Port": FlagPort}).Info("Staring server...")
 	server.Start()
 
 }
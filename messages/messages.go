@@ -1,5 +1,7 @@
 package messages
 
+import "fmt"
+
 type Header uint16
 
 const (
@@ -8,16 +10,116 @@ const (
 	Hello         Header = 0x0200
 	Data          Header = 0x0300
 	Disconnecting Header = 0x0400
+	Fragment      Header = 0x0500 // UDP only, carries a piece of a packet that didn't fit in one datagram
+	Sequenced     Header = 0x0600 // UDP only, wraps a packet sent on one of the Channel channels
+	Punch         Header = 0x0700 // NAT hole punching introductions, flags are Introduction
+	Relay         Header = 0x0800 // UDP only, data between two peers that couldn't punch through
 	Rejected      Header = 0xFFFF
 	ImHere        Header = 0xAAAA
 )
 
+// Headers lists every header, in the order they are declared above
+var Headers = []Header{Ack, Echo, Hello, Data, Disconnecting, Fragment, Sequenced, Punch, Relay, Rejected, ImHere}
+
+func (h Header) String() string {
+	switch h {
+	case Ack:
+		return "Ack"
+	case Echo:
+		return "Echo"
+	case Hello:
+		return "Hello"
+	case Data:
+		return "Data"
+	case Disconnecting:
+		return "Disconnecting"
+	case Fragment:
+		return "Fragment"
+	case Sequenced:
+		return "Sequenced"
+	case Punch:
+		return "Punch"
+	case Relay:
+		return "Relay"
+	case Rejected:
+		return "Rejected"
+	case ImHere:
+		return "ImHere"
+	}
+	return fmt.Sprintf("Header(0x%04X)", uint16(h))
+}
+
+// Capability bits are exchanged in Hello and IDAssign, the server answers with the ones both sides support
+type Capability uint32
+
+type CapabilityStruct struct {
+	Compression Capability
+	Encryption  Capability
+}
+
+var Capabilities = CapabilityStruct{
+	Compression: 1 << 0,
+	Encryption:  1 << 1,
+}
+
 type Flag uint8
 
 const (
 	None Flag = 0x00
 )
 
+// UDP delivery channels, used as the flag of Sequenced and Ack packets.
+// Plain (unwrapped) UDP packets are the unreliable channel.
+type ChannelStruct struct {
+	UnreliableSequenced Flag
+	ReliableOrdered     Flag
+}
+
+var Channel = ChannelStruct{
+	UnreliableSequenced: 0x01,
+	ReliableOrdered:     0x02,
+}
+
+// Flags of Echo packets. Either side may ping, the other answers with a Pong carrying the same payload.
+type HeartbeatStruct struct {
+	Ping Flag
+	Pong Flag
+}
+
+var Heartbeat = HeartbeatStruct{
+	Ping: 0x00,
+	Pong: 0x01,
+}
+
+// Flags of ImHere packets, the UDP bind handshake. The client asks for a challenge over UDP,
+// answers it with a MAC made with the secret it got over TCP and the server confirms with Bound.
+type BindStruct struct {
+	Request   Flag
+	Challenge Flag
+	Response  Flag
+	Bound     Flag
+}
+
+var Bind = BindStruct{
+	Request:   0x00,
+	Challenge: 0x01,
+	Response:  0x02,
+	Bound:     0x03,
+}
+
+// Flags of Punch packets. Request and Result come from clients, the server sends Introduce and Result.
+type IntroductionStruct struct {
+	Request   Flag
+	Introduce Flag
+	Result    Flag
+}
+
+var Introduction = IntroductionStruct{
+	Request:   0x00,
+	Introduce: 0x01,
+	Result:    0x02,
+}
+
 // Nested structures for better organization
 type RequestStruct struct {
 	PlayerList       Flag
@@ -53,6 +155,10 @@ type PostStruct struct {
 	Transform              Flag
 	NetworkVarSync         Flag
 	ChatMessage            Flag
+	TransferOwnership      Flag
+	KickPlayer             Flag
+	BanPlayer              Flag
+	JoinLobbyByCode        Flag
 }
 
 var Post = PostStruct{
@@ -73,6 +179,10 @@ var Post = PostStruct{
 	Transform:              0xAF,
 	NetworkVarSync:         0xBE,
 	ChatMessage:            0xE1,
+	TransferOwnership:      0x12,
+	KickPlayer:             0x13,
+	BanPlayer:              0x14,
+	JoinLobbyByCode:        0x15,
 }
 
 type ResponseStruct struct {
@@ -100,6 +210,7 @@ type ResponseStruct struct {
 	FragmentReceived       Flag
 	NetworkVarSync         Flag
 	ChatMessage            Flag
+	Kicked                 Flag
 }
 
 var Response = ResponseStruct{
@@ -127,4 +238,5 @@ var Response = ResponseStruct{
 	FragmentReceived:       0xDF,
 	NetworkVarSync:         0xEE,
 	ChatMessage:            0xE0,
+	Kicked:                 0x0B,
 }
@@ -0,0 +1,114 @@
+// Package monotag parses the `mono:"..."` struct tags that describe how a payload field goes on the wire.
+// It is shared by the reflection serializer in GameServer and by tools/monogen so both read tags the same way.
+//
+// Options are comma separated:
+//
+//   - "-" field is never sent
+//   - "max=N" strings, slices and maps may hold at most N elements, for strings that is bytes of UTF-8, not characters
+//   - "wire=T" integers are sent as the (narrower) integer type T, e.g. wire=int16
+//   - "since=V" field only exists from protocol version V on, older peers neither send nor expect it
+//   - "optional" field may be missing at the end of the payload and is left zero then, only optional fields may follow it
+//
+// For example:
+//
+//	Name  string  `mono:"max=32"`
+//	Ping  int32   `mono:"wire=uint16,since=2"`
+//	Cache []byte  `mono:"-"`
+package monotag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const Key = "mono"
+
+type Tag struct {
+	Skip     bool
+	Max      int
+	Wire     string
+	Since    int32
+	Optional bool
+}
+
+type IntType struct {
+	Signed bool
+	Bits   int
+}
+
+// IntTypes are the integer types allowed on the wire
+var IntTypes = map[string]IntType{
+	"int8":   {true, 8},
+	"int16":  {true, 16},
+	"int32":  {true, 32},
+	"int64":  {true, 64},
+	"uint8":  {false, 8},
+	"byte":   {false, 8},
+	"uint16": {false, 16},
+	"uint32": {false, 32},
+	"uint64": {false, 64},
+}
+
+// Parse reads the value of a mono tag, an empty string gives the zero Tag
+func Parse(tag string) (Tag, error) {
+	var t Tag
+	if tag == "" {
+		return t, nil
+	}
+	for _, opt := range strings.Split(tag, ",") {
+		opt = strings.TrimSpace(opt)
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "-":
+			t.Skip = true
+		case "max":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return t, fmt.Errorf("invalid max %q", value)
+			}
+			t.Max = n
+		case "wire":
+			if _, ok := IntTypes[value]; !ok {
+				return t, fmt.Errorf("invalid wire type %q", value)
+			}
+			t.Wire = value
+		case "optional":
+			t.Optional = true
+		case "since":
+			n, err := strconv.ParseInt(value, 10, 32)
+			if err != nil || n < 0 {
+				return t, fmt.Errorf("invalid since %q", value)
+			}
+			t.Since = int32(n)
+		default:
+			return t, fmt.Errorf("unknown option %q", opt)
+		}
+	}
+	return t, nil
+}
+
+// Contains reports whether every value of o can be stored in t
+func (t IntType) Contains(o IntType) bool {
+	if t.Signed == o.Signed {
+		return o.Bits <= t.Bits
+	}
+	if t.Signed {
+		return o.Bits < t.Bits
+	}
+	return false
+}
+
+func (t IntType) FitsInt(v int64) bool {
+	if t.Signed {
+		return t.Bits == 64 || (v >= -(1<<(t.Bits-1)) && v < 1<<(t.Bits-1))
+	}
+	return v >= 0 && (t.Bits == 64 || v < 1<<t.Bits)
+}
+
+func (t IntType) FitsUint(v uint64) bool {
+	if t.Signed {
+		return v < 1<<(t.Bits-1)
+	}
+	return t.Bits == 64 || v < 1<<t.Bits
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"MonophobiaServer/GameServer"
+)
+
+// runSchema handles the schema subcommand, which exports the protocol for client code generation
+func runSchema(args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	jsonPath := fs.String("json", "-", "Write the JSON schema to this file, - for stdout, empty to skip")
+	csPath := fs.String("cs", "", "Write the generated C# file to this path, empty to skip")
+	namespace := fs.String("namespace", "Monophobia.Protocol", "Namespace of the generated C# code")
+	fs.Parse(args)
+
+	schema, err := GameServer.ExportSchema()
+	if err != nil {
+		return err
+	}
+	if *jsonPath != "" {
+		if err := writeOutput(*jsonPath, schema.WriteJSON); err != nil {
+			return err
+		}
+	}
+	if *csPath != "" {
+		return writeOutput(*csPath, func(w io.Writer) error {
+			return schema.WriteCSharp(w, *namespace)
+		})
+	}
+	return nil
+}
+
+func writeOutput(path string, write func(io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,489 @@
+// monogen generates MarshalMono/UnmarshalMono methods for payload structs.
+// The generated code writes the same wire layout as the reflection serializer in GameServer/packet.go,
+// so types can be switched over one at a time without the client noticing.
+//
+// Usage (from a go:generate line inside the package):
+//
+//	monogen -output codec_gen.go TypeA TypeB ...
+//
+// Struct types referenced by the listed types are generated as well.
+// `mono` struct tags are honored the same way the reflection serializer does (see package monotag).
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"MonophobiaServer/monotag"
+)
+
+var (
+	flagOutput = flag.String("output", "codec_gen.go", "Output file name")
+	flagDir    = flag.String("dir", ".", "Package directory to read")
+)
+
+type basicCodec struct {
+	write string
+	read  string
+}
+
+// Basic types and the helpers in GameServer/codec.go that encode them.
+// int and uint are left out on purpose, their size depends on the platform.
+var basics = map[string]basicCodec{
+	"bool":    {"writeBool", "readBool"},
+	"int8":    {"writeInt8", "readInt8"},
+	"int16":   {"writeInt16", "readInt16"},
+	"int32":   {"writeInt32", "readInt32"},
+	"int64":   {"writeInt64", "readInt64"},
+	"uint8":   {"writeUint8", "readUint8"},
+	"byte":    {"writeUint8", "readUint8"},
+	"uint16":  {"writeUint16", "readUint16"},
+	"uint32":  {"writeUint32", "readUint32"},
+	"uint64":  {"writeUint64", "readUint64"},
+	"float32": {"writeFloat32", "readFloat32"},
+	"float64": {"writeFloat64", "readFloat64"},
+	"string":  {"writeString", "readString"},
+}
+
+// Map keys are sorted in their natural order, which the reflection serializer only knows for these
+var orderedKeys = map[string]bool{
+	"int8": true, "int16": true, "int32": true, "int64": true,
+	"uint8": true, "byte": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true, "string": true,
+}
+
+type generator struct {
+	out     bytes.Buffer
+	structs map[string]*ast.StructType
+	pkg     string
+	usesMap bool
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "monogen: no types given")
+		os.Exit(2)
+	}
+
+	g := &generator{structs: map[string]*ast.StructType{}}
+	if err := g.load(*flagDir, *flagOutput); err != nil {
+		fmt.Fprintln(os.Stderr, "monogen:", err)
+		os.Exit(1)
+	}
+
+	names, err := g.reachable(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "monogen:", err)
+		os.Exit(1)
+	}
+
+	for _, name := range names {
+		if err := g.generate(name); err != nil {
+			fmt.Fprintln(os.Stderr, "monogen:", err)
+			os.Exit(1)
+		}
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "// Code generated by monogen. DO NOT EDIT.\n\npackage %s\n\n", g.pkg)
+	fmt.Fprintf(&file, "import (\n\t\"bytes\"\n\t\"fmt\"\n")
+	if g.usesMap {
+		fmt.Fprintf(&file, "\t\"maps\"\n\t\"slices\"\n")
+	}
+	fmt.Fprintf(&file, ")\n\n")
+	file.Write(g.out.Bytes())
+
+	src, err := format.Source(file.Bytes())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "monogen: generated invalid code:", err)
+		os.Stderr.Write(file.Bytes())
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filepath.Join(*flagDir, *flagOutput), src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "monogen:", err)
+		os.Exit(1)
+	}
+}
+
+// load parses every non test file of the package except the previous output
+func (g *generator) load(dir, output string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	for _, path := range files {
+		base := filepath.Base(path)
+		if base == output || strings.HasSuffix(base, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		g.pkg = f.Name.Name
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok {
+					g.structs[ts.Name.Name] = st
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// reachable returns the requested types plus every struct type of the package they reference, sorted
+func (g *generator) reachable(roots []string) ([]string, error) {
+	seen := map[string]bool{}
+	var visit func(name string) error
+	var walk func(t ast.Expr) error
+	walk = func(t ast.Expr) error {
+		switch t := t.(type) {
+		case *ast.Ident:
+			if _, ok := g.structs[t.Name]; ok {
+				return visit(t.Name)
+			}
+		case *ast.StarExpr:
+			return walk(t.X)
+		case *ast.ArrayType:
+			return walk(t.Elt)
+		case *ast.MapType:
+			return walk(t.Value)
+		}
+		return nil
+	}
+	visit = func(name string) error {
+		if seen[name] {
+			return nil
+		}
+		st, ok := g.structs[name]
+		if !ok {
+			return fmt.Errorf("struct type %s not found", name)
+		}
+		seen[name] = true
+		for _, field := range st.Fields.List {
+			if err := walk(field.Type); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, name := range roots {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.out, format, args...)
+}
+
+// wireField is a struct field that goes on the wire
+type wireField struct {
+	name string
+	typ  ast.Expr
+	tag  monotag.Tag
+}
+
+// wireFields mirrors wireFields in GameServer/packet.go: unexported and skipped fields are left out
+func (g *generator) wireFields(name string) ([]wireField, error) {
+	var fields []wireField
+	optional := false
+	for _, field := range g.structs[name].Fields.List {
+		var tag monotag.Tag
+		if field.Tag != nil {
+			raw, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			if tag, err = monotag.Parse(reflect.StructTag(raw).Get(monotag.Key)); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		if tag.Skip {
+			continue
+		}
+		for _, fname := range fieldNames(field) {
+			if !ast.IsExported(fname) {
+				continue
+			}
+			if optional && !tag.Optional {
+				return nil, fmt.Errorf("%s.%s: follows an optional field but isn't optional", name, fname)
+			}
+			optional = tag.Optional
+			fields = append(fields, wireField{fname, field.Type, tag})
+		}
+	}
+	return fields, nil
+}
+
+func (g *generator) generate(name string) error {
+	fields, err := g.wireFields(name)
+	if err != nil {
+		return err
+	}
+
+	g.printf("var _ MonoMarshaler = (*%s)(nil)\nvar _ MonoUnmarshaler = (*%s)(nil)\n\n", name, name)
+
+	g.printf("func (x *%s) MarshalMono(buf *bytes.Buffer, version int32) error {\n", name)
+	for _, f := range fields {
+		g.sinceOpen(f.tag)
+		if err := g.encode("x."+f.name, name+"."+f.name, f.typ, f.tag, 0); err != nil {
+			return err
+		}
+		g.sinceClose(f.tag)
+	}
+	g.printf("return nil\n}\n\n")
+
+	g.printf("func (x *%s) UnmarshalMono(r *bytes.Reader, version int32) error {\n", name)
+	g.printf("var err error\n")
+	for _, f := range fields {
+		g.sinceOpen(f.tag)
+		if f.tag.Optional {
+			g.printf("if r.Len() == 0 {\nreturn nil\n}\n")
+		}
+		if err := g.decode("x."+f.name, name+"."+f.name, f.typ, f.tag, 0); err != nil {
+			return err
+		}
+		g.sinceClose(f.tag)
+	}
+	g.printf("return err\n}\n\n")
+	return nil
+}
+
+func (g *generator) sinceOpen(tag monotag.Tag) {
+	if tag.Since > 0 {
+		g.printf("if version >= %d {\n", tag.Since)
+	}
+}
+
+func (g *generator) sinceClose(tag monotag.Tag) {
+	if tag.Since > 0 {
+		g.printf("}\n")
+	}
+}
+
+func fieldNames(field *ast.Field) []string {
+	if len(field.Names) == 0 {
+		// embedded field, named after its type
+		return []string{types.ExprString(field.Type)}
+	}
+	names := make([]string, len(field.Names))
+	for i, n := range field.Names {
+		names[i] = n.Name
+	}
+	return names
+}
+
+// wireInt returns the native and wire integer types of a field tagged with wire, ok is false without the tag
+func wireInt(path, native string, tag monotag.Tag) (monotag.IntType, monotag.IntType, bool, error) {
+	if tag.Wire == "" {
+		return monotag.IntType{}, monotag.IntType{}, false, nil
+	}
+	nt, ok := monotag.IntTypes[native]
+	if !ok {
+		return nt, nt, false, fmt.Errorf("%s: wire=%s on non integer type %s", path, tag.Wire, native)
+	}
+	wt := monotag.IntTypes[tag.Wire]
+	if !nt.Contains(wt) {
+		return nt, wt, false, fmt.Errorf("%s: wire=%s does not fit into %s", path, tag.Wire, native)
+	}
+	return nt, wt, true, nil
+}
+
+// intBounds returns the smallest and largest value of an integer type as Go literals
+func intBounds(t monotag.IntType) (string, string) {
+	if t.Signed {
+		return "-" + new(big.Int).Lsh(big.NewInt(1), uint(t.Bits-1)).String(),
+			new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(t.Bits-1)), big.NewInt(1)).String()
+	}
+	return "0", new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(t.Bits)), big.NewInt(1)).String()
+}
+
+func (g *generator) encode(expr, path string, t ast.Expr, tag monotag.Tag, depth int) error {
+	// max only limits the outermost container, wire is passed down to the integers inside
+	elemTag := monotag.Tag{Wire: tag.Wire}
+	switch t := t.(type) {
+	case *ast.Ident:
+		if b, ok := basics[t.Name]; ok {
+			nt, wt, narrowed, err := wireInt(path, t.Name, tag)
+			if err != nil {
+				return err
+			}
+			if narrowed {
+				if nt != wt {
+					min, max := intBounds(wt)
+					cond := fmt.Sprintf("%s > %s", expr, max)
+					if nt.Signed {
+						cond = fmt.Sprintf("%s < %s || %s", expr, min, cond)
+					}
+					g.printf("if %s {\nreturn fmt.Errorf(\"%s: value %%d does not fit into %s\", %s)\n}\n", cond, path, tag.Wire, expr)
+				}
+				g.printf("%s(buf, %s(%s))\n", basics[tag.Wire].write, tag.Wire, expr)
+				return nil
+			}
+			if t.Name == "string" && tag.Max > 0 {
+				g.printf("if len(%s) > %d {\nreturn fmt.Errorf(\"%s: string length %%d over maximum of %d\", len(%s))\n}\n", expr, tag.Max, path, tag.Max, expr)
+			}
+			g.printf("%s(buf, %s)\n", b.write, expr)
+			return nil
+		}
+		if _, ok := g.structs[t.Name]; ok {
+			g.printf("if err := %s.MarshalMono(buf, version); err != nil {\nreturn fmt.Errorf(\"%s: %%w\", err)\n}\n", expr, path)
+			return nil
+		}
+	case *ast.ParenExpr:
+		return g.encode(expr, path, t.X, tag, depth)
+	case *ast.StarExpr:
+		g.printf("if %s == nil {\nwriteBool(buf, false)\n} else {\nwriteBool(buf, true)\n", expr)
+		if err := g.encode("(*"+expr+")", path, t.X, tag, depth); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	case *ast.ArrayType:
+		idx := fmt.Sprintf("i%d", depth)
+		if t.Len == nil {
+			g.maxCheck(expr, path, tag)
+			g.printf("writeInt32(buf, int32(len(%s)))\n", expr)
+		}
+		g.printf("for %s := range %s {\n", idx, expr)
+		if err := g.encode(expr+"["+idx+"]", path+"[]", t.Elt, elemTag, depth+1); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	case *ast.MapType:
+		key, ok := t.Key.(*ast.Ident)
+		if !ok || !orderedKeys[key.Name] {
+			return fmt.Errorf("%s: unsupported map key type %s", path, types.ExprString(t.Key))
+		}
+		g.usesMap = true
+		k := fmt.Sprintf("k%d", depth)
+		v := fmt.Sprintf("v%d", depth)
+		g.maxCheck(expr, path, tag)
+		g.printf("writeInt32(buf, int32(len(%s)))\n", expr)
+		// map values aren't addressable, copy them out so pointer receivers work
+		g.printf("for _, %s := range slices.Sorted(maps.Keys(%s)) {\n%s := %s[%s]\n", k, expr, v, expr, k)
+		if err := g.encode(k, path+"{key}", t.Key, monotag.Tag{}, depth+1); err != nil {
+			return err
+		}
+		if err := g.encode(v, path+"{}", t.Value, elemTag, depth+1); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	}
+	return fmt.Errorf("%s: unsupported type %s", path, types.ExprString(t))
+}
+
+func (g *generator) maxCheck(expr, path string, tag monotag.Tag) {
+	if tag.Max > 0 {
+		g.printf("if len(%s) > %d {\nreturn fmt.Errorf(\"%s: length %%d over maximum of %d\", len(%s))\n}\n", expr, tag.Max, path, tag.Max, expr)
+	}
+}
+
+func (g *generator) decode(expr, path string, t ast.Expr, tag monotag.Tag, depth int) error {
+	elemTag := monotag.Tag{Wire: tag.Wire}
+	switch t := t.(type) {
+	case *ast.Ident:
+		if b, ok := basics[t.Name]; ok {
+			_, _, narrowed, err := wireInt(path, t.Name, tag)
+			if err != nil {
+				return err
+			}
+			if narrowed {
+				w := fmt.Sprintf("w%d", depth)
+				g.printf("{\n%s, err := %s(r)\nif err != nil {\nreturn fmt.Errorf(\"%s: %%w\", err)\n}\n%s = %s(%s)\n}\n", w, basics[tag.Wire].read, path, expr, t.Name, w)
+				return nil
+			}
+			if t.Name == "string" && tag.Max > 0 {
+				g.printf("if %s, err = readStringMax(r, %d); err != nil {\nreturn fmt.Errorf(\"%s: %%w\", err)\n}\n", expr, tag.Max, path)
+				return nil
+			}
+			g.printf("if %s, err = %s(r); err != nil {\nreturn fmt.Errorf(\"%s: %%w\", err)\n}\n", expr, b.read, path)
+			return nil
+		}
+		if _, ok := g.structs[t.Name]; ok {
+			g.printf("if err = %s.UnmarshalMono(r, version); err != nil {\nreturn fmt.Errorf(\"%s: %%w\", err)\n}\n", expr, path)
+			return nil
+		}
+	case *ast.ParenExpr:
+		return g.decode(expr, path, t.X, tag, depth)
+	case *ast.StarExpr:
+		present := fmt.Sprintf("p%d", depth)
+		g.printf("{\n%s, err := readPresence(r)\nif err != nil {\nreturn fmt.Errorf(\"%s: %%w\", err)\n}\n", present, path)
+		g.printf("%s = nil\nif %s {\n%s = new(%s)\n", expr, present, expr, types.ExprString(t.X))
+		if err := g.decode("(*"+expr+")", path, t.X, tag, depth+1); err != nil {
+			return err
+		}
+		g.printf("}\n}\n")
+		return nil
+	case *ast.ArrayType:
+		idx := fmt.Sprintf("i%d", depth)
+		if t.Len != nil {
+			g.printf("for %s := range %s {\n", idx, expr)
+			if err := g.decode(expr+"["+idx+"]", path+"[]", t.Elt, elemTag, depth+1); err != nil {
+				return err
+			}
+			g.printf("}\n")
+			return nil
+		}
+		n := fmt.Sprintf("n%d", depth)
+		g.printf("{\n%s, err := readLengthMax(r, %d)\nif err != nil {\nreturn fmt.Errorf(\"%s: %%w\", err)\n}\n", n, tag.Max, path)
+		g.printf("%s = make(%s, %s)\n", expr, types.ExprString(t), n)
+		g.printf("for %s := range %s {\n", idx, expr)
+		if err := g.decode(expr+"["+idx+"]", path+"[]", t.Elt, elemTag, depth+1); err != nil {
+			return err
+		}
+		g.printf("}\n}\n")
+		return nil
+	case *ast.MapType:
+		key, ok := t.Key.(*ast.Ident)
+		if !ok || !orderedKeys[key.Name] {
+			return fmt.Errorf("%s: unsupported map key type %s", path, types.ExprString(t.Key))
+		}
+		n := fmt.Sprintf("n%d", depth)
+		k := fmt.Sprintf("k%d", depth)
+		v := fmt.Sprintf("v%d", depth)
+		g.printf("{\n%s, err := readLengthMax(r, %d)\nif err != nil {\nreturn fmt.Errorf(\"%s: %%w\", err)\n}\n", n, tag.Max, path)
+		g.printf("%s = make(%s, %s)\n", expr, types.ExprString(t), n)
+		g.printf("for range %s {\nvar %s %s\nvar %s %s\n", n, k, types.ExprString(t.Key), v, types.ExprString(t.Value))
+		if err := g.decode(k, path+"{key}", t.Key, monotag.Tag{}, depth+1); err != nil {
+			return err
+		}
+		if err := g.decode(v, path+"{}", t.Value, elemTag, depth+1); err != nil {
+			return err
+		}
+		g.printf("%s[%s] = %s\n}\n}\n", expr, k, v)
+		return nil
+	}
+	return fmt.Errorf("%s: unsupported type %s", path, types.ExprString(t))
+}